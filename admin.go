@@ -0,0 +1,201 @@
+package prestgo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// QuerySummary describes a query known to the coordinator, as listed by its
+// /v1/query endpoint.
+type QuerySummary struct {
+	QueryID string
+	State   string
+	Query   string
+	InfoURI string
+
+	User    string
+	Source  string
+	Catalog string
+	Schema  string
+
+	CreateTime    time.Time
+	EndTime       time.Time // zero until the query has finished
+	QueuedTime    time.Duration
+	ElapsedTime   time.Duration
+	ExecutionTime time.Duration
+	CPUTime       time.Duration
+
+	// ErrorType, ErrorCode and ErrorName are set when the query has failed.
+	ErrorType string
+	ErrorCode int
+	ErrorName string
+}
+
+// QueryDetail holds the full information about a single query.
+type QueryDetail struct {
+	QuerySummary
+
+	// Failure is the cause of a failed query.
+	Failure *FailureInfo
+
+	Warnings []Warning
+
+	// Raw is the complete response from the coordinator, which includes details not
+	// decoded by the driver such as the query plan and the statistics of each stage.
+	Raw json.RawMessage
+}
+
+// QueryFilter selects the queries returned by ClusterClient.Queries. Empty fields
+// match all queries.
+type QueryFilter struct {
+	State  string
+	User   string
+	Source string
+}
+
+func (f QueryFilter) match(q *QuerySummary) bool {
+	return (f.State == "" || strings.EqualFold(f.State, q.State)) &&
+		(f.User == "" || f.User == q.User) &&
+		(f.Source == "" || f.Source == q.Source)
+}
+
+// Queries returns the queries known to the coordinator that match filter.
+func (cc *ClusterClient) Queries(ctx context.Context, filter QueryFilter) ([]QuerySummary, error) {
+	path := "/v1/query"
+	if filter.State != "" {
+		path += "?state=" + url.QueryEscape(strings.ToUpper(filter.State))
+	}
+
+	var infos []infoResponse
+	if err := cc.get(ctx, path, &infos); err != nil {
+		return nil, err
+	}
+
+	var queries []QuerySummary
+	for i := range infos {
+		q, err := infos[i].summary()
+		if err != nil {
+			return nil, err
+		}
+		// The server may not support filtering by state
+		if filter.match(&q) {
+			queries = append(queries, q)
+		}
+	}
+	return queries, nil
+}
+
+// Query returns the full information about the query with the given id.
+func (cc *ClusterClient) Query(ctx context.Context, queryID string) (*QueryDetail, error) {
+	var raw json.RawMessage
+	if err := cc.get(ctx, "/v1/query/"+url.PathEscape(queryID), &raw); err != nil {
+		return nil, err
+	}
+
+	var info infoResponse
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, err
+	}
+	summary, err := info.summary()
+	if err != nil {
+		return nil, err
+	}
+	return &QueryDetail{
+		QuerySummary: summary,
+		Failure:      info.FailureInfo,
+		Warnings:     info.Warnings,
+		Raw:          raw,
+	}, nil
+}
+
+// KillQuery stops the query with the given id. If message is not empty the query
+// fails with it as its error message, otherwise the query is canceled.
+func (cc *ClusterClient) KillQuery(ctx context.Context, queryID, message string) error {
+	path := "/v1/query/" + url.PathEscape(queryID)
+	method := "DELETE"
+	if message != "" {
+		path += "/killed"
+		method = "PUT"
+	}
+
+	dc, err := cc.connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	c := dc.(*conn)
+	defer c.Close()
+	return c.eachHost(ctx, func(addr string) error {
+		if err := c.resolveProtocol(ctx, addr); err != nil {
+			return err
+		}
+		req, err := http.NewRequest(method, c.serverURL(addr, path), strings.NewReader(message))
+		if err != nil {
+			return err
+		}
+		req.Header.Add(c.header("User"), c.user)
+		req.Header.Set("Content-Type", "text/plain")
+
+		resp, err := c.do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return newHTTPError(resp)
+		}
+		return nil
+	})
+}
+
+func (info *infoResponse) summary() (QuerySummary, error) {
+	q := QuerySummary{
+		QueryID:   info.QueryID,
+		State:     info.State,
+		Query:     info.Query,
+		InfoURI:   info.Self,
+		User:      info.Session.User,
+		Source:    info.Session.Source,
+		Catalog:   info.Session.Catalog,
+		Schema:    info.Session.Schema,
+		ErrorType: info.ErrorType,
+	}
+	if info.ErrorCode != nil {
+		q.ErrorCode = info.ErrorCode.Code
+		q.ErrorName = info.ErrorCode.Name
+	}
+
+	stats := &info.QueryStats
+	var err error
+	if q.CreateTime, err = parseServerTime(stats.CreateTime); err != nil {
+		return q, err
+	}
+	if q.EndTime, err = parseServerTime(stats.EndTime); err != nil {
+		return q, err
+	}
+	for _, d := range []struct {
+		s string
+		d *time.Duration
+	}{
+		{stats.QueuedTime, &q.QueuedTime},
+		{stats.ElapsedTime, &q.ElapsedTime},
+		{stats.ExecutionTime, &q.ExecutionTime},
+		{stats.CPUTime, &q.CPUTime},
+	} {
+		if *d.d, err = parseServerDuration(d.s); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// parseServerTime parses a time reported by Presto. An empty string is the zero time.
+func parseServerTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,195 @@
+package prestgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const queryListResponse = `[
+  {
+    "queryId": "20200301_120000_00001_abcde", "state": "RUNNING", "query": "SELECT * FROM big",
+    "self": "http://coordinator/v1/query/20200301_120000_00001_abcde",
+    "session": {"user": "etl", "source": "scheduler", "catalog": "hive", "schema": "default"},
+    "queryStats": {"createTime": "2020-03-01T12:00:00.000Z", "queuedTime": "1.00s", "elapsedTime": "2.00h", "executionTime": "1.90h", "totalCpuTime": "30.00d"}
+  },
+  {
+    "queryId": "20200301_120000_00002_abcde", "state": "FAILED", "query": "SELECT x",
+    "session": {"user": "analyst", "source": "prq"},
+    "queryStats": {"createTime": "2020-03-01T12:00:00.000Z", "endTime": "2020-03-01T12:00:01.500Z", "elapsedTime": "1.50s"},
+    "errorType": "USER_ERROR", "errorCode": {"code": 1, "name": "SYNTAX_ERROR", "type": "USER_ERROR"}
+  }
+]`
+
+type adminServer struct {
+	mu      sync.Mutex
+	states  []string
+	killed  []string
+	message string
+}
+
+func (s *adminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/query" && r.Method == "GET":
+		s.states = append(s.states, r.URL.Query().Get("state"))
+		fmt.Fprint(w, queryListResponse)
+	case r.URL.Path == "/v1/query/20200301_120000_00002_abcde" && r.Method == "GET":
+		fmt.Fprint(w, `{
+		  "queryId": "20200301_120000_00002_abcde", "state": "FAILED", "query": "SELECT x",
+		  "session": {"user": "analyst"},
+		  "queryStats": {"createTime": "2020-03-01T12:00:00.000Z"},
+		  "errorType": "USER_ERROR", "errorCode": {"code": 1, "name": "SYNTAX_ERROR", "type": "USER_ERROR"},
+		  "failureInfo": {"type": "com.facebook.presto.sql.parser.ParsingException", "message": "line 1:8: Column 'x' cannot be resolved"},
+		  "warnings": [{"warningCode": {"code": 1, "name": "PARSER_WARNING"}, "message": "m"}],
+		  "outputStage": {"stageId": "0"}
+		}`)
+	case r.URL.Path == "/v1/query/20200301_120000_00001_abcde" && r.Method == "DELETE":
+		s.killed = append(s.killed, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	case r.URL.Path == "/v1/query/20200301_120000_00001_abcde/killed" && r.Method == "PUT":
+		body, _ := ioutil.ReadAll(r.Body)
+		s.killed = append(s.killed, r.URL.Path)
+		s.message = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newAdminClient(t *testing.T) (*ClusterClient, *adminServer, func()) {
+	s := &adminServer{}
+	ts := httptest.NewServer(s)
+	cc, err := NewClusterClient(Config{DSN: "presto://" + strings.TrimPrefix(ts.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cc, s, ts.Close
+}
+
+func TestQueries(t *testing.T) {
+	cc, s, done := newAdminClient(t)
+	defer done()
+
+	testCases := []struct {
+		filter   QueryFilter
+		expected []string
+	}{
+		{filter: QueryFilter{}, expected: []string{"20200301_120000_00001_abcde", "20200301_120000_00002_abcde"}},
+		{filter: QueryFilter{State: "running"}, expected: []string{"20200301_120000_00001_abcde"}},
+		{filter: QueryFilter{User: "analyst"}, expected: []string{"20200301_120000_00002_abcde"}},
+		{filter: QueryFilter{Source: "scheduler", User: "analyst"}},
+	}
+
+	for _, tc := range testCases {
+		queries, err := cc.Queries(context.Background(), tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, q := range queries {
+			ids = append(ids, q.QueryID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%+v: got %v, wanted %v", tc.filter, ids, tc.expected)
+		}
+	}
+	if s.states[1] != "RUNNING" {
+		t.Errorf("got state parameter %q, wanted %q", s.states[1], "RUNNING")
+	}
+
+	queries, _ := cc.Queries(context.Background(), QueryFilter{})
+	q := queries[0]
+	if q.User != "etl" || q.Source != "scheduler" || q.Catalog != "hive" || q.InfoURI == "" {
+		t.Errorf("got session %+v", q)
+	}
+	if q.ElapsedTime != 2*time.Hour || q.CPUTime != 30*24*time.Hour || !q.EndTime.IsZero() {
+		t.Errorf("got stats %+v", q)
+	}
+	if q := queries[1]; q.ErrorName != "SYNTAX_ERROR" || q.ErrorType != ErrorTypeUser || q.EndTime.Sub(q.CreateTime) != 1500*time.Millisecond {
+		t.Errorf("got failed query %+v", q)
+	}
+}
+
+func TestQueryDetail(t *testing.T) {
+	cc, _, done := newAdminClient(t)
+	defer done()
+
+	q, err := cc.Query(context.Background(), "20200301_120000_00002_abcde")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.State != QueryStateFailed || q.ErrorCode != 1 || q.Failure == nil || !strings.Contains(q.Failure.Message, "cannot be resolved") {
+		t.Errorf("got query %+v", q)
+	}
+	if len(q.Warnings) != 1 || !strings.Contains(string(q.Raw), "outputStage") {
+		t.Errorf("got warnings %v and raw %s", q.Warnings, q.Raw)
+	}
+
+	_, err = cc.Query(context.Background(), "unknown")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, wanted a not found HTTPError", err)
+	}
+}
+
+func TestKillQuery(t *testing.T) {
+	cc, s, done := newAdminClient(t)
+	defer done()
+
+	if err := cc.KillQuery(context.Background(), "20200301_120000_00001_abcde", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.KillQuery(context.Background(), "20200301_120000_00001_abcde", "runaway query"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "/v1/query/20200301_120000_00001_abcde,/v1/query/20200301_120000_00001_abcde/killed"
+	if got := strings.Join(s.killed, ","); got != expected {
+		t.Errorf("got %v, wanted %v", got, expected)
+	}
+	if s.message != "runaway query" {
+		t.Errorf("got message %q, wanted %q", s.message, "runaway query")
+	}
+
+	if err := cc.KillQuery(context.Background(), "unknown", ""); err == nil {
+		t.Errorf("got no error killing an unknown query")
+	}
+}
+
+func TestKillQueryStandby(t *testing.T) {
+	standby := httptest.NewServer(http.NotFoundHandler())
+	defer standby.Close()
+	s := &adminServer{}
+	active := httptest.NewServer(s)
+	defer active.Close()
+	standbyAddr := strings.TrimPrefix(standby.URL, "http://")
+
+	cc, err := NewClusterClient(Config{DSN: "presto://" + standbyAddr + "," + strings.TrimPrefix(active.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cc.KillQuery(context.Background(), "20200301_120000_00001_abcde", ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.killed) != 1 {
+		t.Errorf("got %d queries killed, wanted 1", len(s.killed))
+	}
+	if cc.connector.hosts.health.cooling(standbyAddr, DefaultHostCooldown) {
+		t.Errorf("standby coordinator is cooling down after rejecting the request")
+	}
+
+	err = cc.KillQuery(context.Background(), "unknown", "")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, wanted a not found HTTPError", err)
+	}
+}
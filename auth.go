@@ -0,0 +1,249 @@
+package prestgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExternalAuthTimeout is the time the driver waits for a token to be issued
+// during external authentication when Config.ExternalAuthTimeout is not set.
+const DefaultExternalAuthTimeout = 5 * time.Minute
+
+// ErrExternalAuthFailed indicates that the driver could not obtain a token from the
+// Presto server during external authentication.
+var ErrExternalAuthFailed = errors.New(DriverName + ": external authentication failed")
+
+// ExternalAuthHandler is called when a Presto server requests OAuth2 external
+// authentication. It should direct the user to redirectURL, for example by printing it
+// or opening it in a browser, and then return. The driver polls the server for the
+// token issued once the user has authenticated.
+type ExternalAuthHandler func(redirectURL string) error
+
+// tokenPollRetryInterval is the time to wait before polling the token server again
+// after it reports that it is unavailable.
+var tokenPollRetryInterval = time.Second
+
+// tokenCache holds the bearer token obtained through external authentication. Each
+// Connector has its own cache, so the token is used for requests to any of the
+// connector's coordinators but is only shared by connections made with the same
+// credentials and authentication handler.
+type tokenCache struct {
+	mu    sync.Mutex
+	token string
+
+	// auth serializes authentication attempts so that concurrent queries don't each
+	// send the user to authenticate.
+	auth sync.Mutex
+}
+
+func (tc *tokenCache) get() string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.token
+}
+
+func (tc *tokenCache) set(token string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.token = token
+}
+
+// bearerChallenge holds the external authentication parameters sent by a Presto server
+// in the WWW-Authenticate header of a 401 response.
+type bearerChallenge struct {
+	redirectURL string
+	tokenURL    string
+}
+
+// parseBearerChallenge looks for a Bearer challenge naming a token server in the
+// supplied headers.
+func parseBearerChallenge(h http.Header) (bearerChallenge, bool) {
+	for _, v := range h["Www-Authenticate"] {
+		if len(v) < 7 || !strings.EqualFold(v[:7], "bearer ") {
+			continue
+		}
+		params := parseAuthParams(v[7:])
+		ch := bearerChallenge{
+			redirectURL: params["x_redirect_server"],
+			tokenURL:    params["x_token_server"],
+		}
+		if ch.tokenURL != "" {
+			return ch, true
+		}
+	}
+	return bearerChallenge{}, false
+}
+
+// parseAuthParams parses a comma separated list of key=value pairs, where values may
+// be quoted.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end == -1 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end == -1 {
+				val, s = s, ""
+			} else {
+				val, s = s[:end], s[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(val)
+	}
+}
+
+// tokenResponse is the body returned by the token server while polling for a token.
+type tokenResponse struct {
+	Token   string `json:"token"`
+	NextURI string `json:"nextUri"`
+	Error   string `json:"error"`
+}
+
+// do sends req to the Presto server. When the server responds with an OAuth2
+// challenge and an ExternalAuthHandler has been configured, do authenticates and
+// resends the request with the token that was issued. Tokens are only sent by
+// connections that perform external authentication, and only over https.
+func (c *conn) do(req *http.Request) (*http.Response, error) {
+	if c.externalAuth == nil || c.tokens == nil {
+		return c.client.Do(req)
+	}
+
+	secure := req.URL.Scheme == "https"
+	token := c.tokens.get()
+	if token != "" && secure {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	ch, ok := parseBearerChallenge(resp.Header)
+	if !ok {
+		return resp, nil
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if !secure {
+		return nil, fmt.Errorf("%w: tokens are only sent over https, which is enabled by the ssl data source parameter", ErrExternalAuthFailed)
+	}
+
+	token, err = c.authenticate(req.Context(), token, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+	return c.client.Do(retry)
+}
+
+// authenticate performs external authentication, returning the issued token.
+// rejected is the token, if any, that the server refused.
+func (c *conn) authenticate(ctx context.Context, rejected string, ch bearerChallenge) (string, error) {
+	c.tokens.auth.Lock()
+	defer c.tokens.auth.Unlock()
+
+	// Another connection may have authenticated while we waited
+	if token := c.tokens.get(); token != "" && token != rejected {
+		return token, nil
+	}
+
+	if ch.redirectURL != "" {
+		if err := c.externalAuth(ch.redirectURL); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrExternalAuthFailed, err)
+		}
+	}
+
+	timeout := c.externalAuthTimeout
+	if timeout == 0 {
+		timeout = DefaultExternalAuthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	token, err := c.pollToken(ctx, ch.tokenURL)
+	if err != nil {
+		return "", err
+	}
+	c.tokens.set(token)
+	return token, nil
+}
+
+// pollToken polls the token server until a token is issued, an error is reported
+// or ctx is done. The token server holds each request open until it has a result
+// or wishes the client to poll again via nextUri.
+func (c *conn) pollToken(ctx context.Context, tokenURL string) (string, error) {
+	next := tokenURL
+	for {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := c.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrExternalAuthFailed, err)
+		}
+
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			resp.Body.Close()
+			if err := sleep(ctx, tokenPollRetryInterval); err != nil {
+				return "", fmt.Errorf("%w: %v", ErrExternalAuthFailed, err)
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return "", fmt.Errorf("%w: token server returned %s", ErrExternalAuthFailed, resp.Status)
+		}
+
+		var tresp tokenResponse
+		err = json.NewDecoder(resp.Body).Decode(&tresp)
+		resp.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrExternalAuthFailed, err)
+		}
+
+		switch {
+		case tresp.Error != "":
+			return "", fmt.Errorf("%w: %s", ErrExternalAuthFailed, tresp.Error)
+		case tresp.Token != "":
+			return tresp.Token, nil
+		case tresp.NextURI != "":
+			next = tresp.NextURI
+		default:
+			return "", fmt.Errorf("%w: token server returned neither a token nor a next uri", ErrExternalAuthFailed)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseBearerChallenge(t *testing.T) {
+	testCases := []struct {
+		header   []string
+		expected bearerChallenge
+		ok       bool
+	}{
+		{
+			header:   []string{`Bearer x_redirect_server="http://example/oauth2/token/initiate/1", x_token_server="http://example/oauth2/token/1"`},
+			expected: bearerChallenge{redirectURL: "http://example/oauth2/token/initiate/1", tokenURL: "http://example/oauth2/token/1"},
+			ok:       true,
+		},
+		{
+			header:   []string{`Basic realm="presto"`, `Bearer x_token_server=http://example/oauth2/token/1`},
+			expected: bearerChallenge{tokenURL: "http://example/oauth2/token/1"},
+			ok:       true,
+		},
+		{
+			header: []string{`Basic realm="presto"`},
+			ok:     false,
+		},
+		{
+			header: []string{`Bearer realm="presto"`},
+			ok:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		ch, ok := parseBearerChallenge(http.Header{"Www-Authenticate": tc.header})
+		if ok != tc.ok {
+			t.Errorf("%v: got ok=%v, wanted %v", tc.header, ok, tc.ok)
+			continue
+		}
+		if !reflect.DeepEqual(ch, tc.expected) {
+			t.Errorf("%v: got %#v, wanted %#v", tc.header, ch, tc.expected)
+		}
+	}
+}
+
+// externalAuthServer requires a bearer token for statements and issues one after a
+// single round of polling. It must be served using TLS.
+func externalAuthServer(tokenPolls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/statement":
+			if r.Header.Get("Authorization") != "Bearer t0k3n" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer x_redirect_server="https://%[1]s/oauth2/token/initiate/1", x_token_server="https://%[1]s/oauth2/token/1"`, r.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `{"id": "abcd", "nextUri": "https://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+		case "/oauth2/token/1":
+			*tokenPolls++
+			fmt.Fprintf(w, `{"nextUri": "https://%s/oauth2/token/2"}`, r.Host)
+		case "/oauth2/token/2":
+			*tokenPolls++
+			fmt.Fprint(w, `{"token": "t0k3n"}`)
+		default:
+			oneRowColResponse(w, r)
+		}
+	}
+}
+
+func TestExternalAuth(t *testing.T) {
+	var tokenPolls int
+	ts := httptest.NewTLSServer(externalAuthServer(&tokenPolls))
+	defer ts.Close()
+
+	var redirects []string
+	connector, err := NewConnector(Config{
+		DSN:    "presto://" + strings.TrimPrefix(ts.URL, "https://") + "?ssl=true",
+		Client: ts.Client(),
+		ExternalAuth: func(redirectURL string) error {
+			redirects = append(redirects, redirectURL)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		cn, err := connector.Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, _ := cn.Prepare("SELECT 1")
+		r, err := st.Query(nil)
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		values := make([]driver.Value, 1)
+		if err := r.Next(values); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		if values[0] != "c0r0" {
+			t.Errorf("query %d: got %v, wanted %v", i, values[0], "c0r0")
+		}
+	}
+
+	// The token is cached so the second query must not authenticate again
+	expected := []string{ts.URL + "/oauth2/token/initiate/1"}
+	if !reflect.DeepEqual(redirects, expected) {
+		t.Errorf("got redirects %v, wanted %v", redirects, expected)
+	}
+	if tokenPolls != 2 {
+		t.Errorf("got %d token polls, wanted %d", tokenPolls, 2)
+	}
+}
+
+func TestExternalAuthTokenNotShared(t *testing.T) {
+	var tokenPolls int
+	var authorizations []string
+	handler := externalAuthServer(&tokenPolls)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/statement" {
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+		}
+		handler(w, r)
+	}))
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "https://") + "?ssl=true"
+
+	connectors := make([]*Connector, 3)
+	for i, conf := range []Config{
+		{DSN: "presto://alice@" + addr, Client: ts.Client(), ExternalAuth: func(string) error { return nil }},
+		{DSN: "presto://bob@" + addr, Client: ts.Client()},
+		{DSN: "presto://carol@" + addr, Client: ts.Client(), ExternalAuth: func(string) error { return nil }},
+	} {
+		connector, err := NewConnector(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		connectors[i] = connector
+	}
+
+	for _, connector := range connectors {
+		cn, err := connector.Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, _ := cn.Prepare("SELECT 1")
+		if r, err := st.Query(nil); err == nil {
+			r.Close()
+		}
+	}
+
+	// Each connector that authenticates obtains its own token, and the connector
+	// without external authentication sends none
+	expected := []string{"", "Bearer t0k3n", "", "", "Bearer t0k3n"}
+	if !reflect.DeepEqual(authorizations, expected) {
+		t.Errorf("got authorizations %q, wanted %q", authorizations, expected)
+	}
+	if tokenPolls != 4 {
+		t.Errorf("got %d token polls, wanted %d", tokenPolls, 4)
+	}
+}
+
+func TestExternalAuthTokenError(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/statement":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer x_token_server="https://%s/oauth2/token/1"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/oauth2/token/1":
+			fmt.Fprint(w, `{"error": "access denied"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	connector, err := NewConnector(Config{
+		DSN:          "presto://" + strings.TrimPrefix(ts.URL, "https://") + "?ssl=true",
+		Client:       ts.Client(),
+		ExternalAuth: func(string) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+	st, _ := cn.Prepare("SELECT 1")
+	_, err = st.Query(nil)
+	if !errors.Is(err, ErrExternalAuthFailed) {
+		t.Fatalf("got %v, wanted ErrExternalAuthFailed", err)
+	}
+}
+
+func TestExternalAuthTokenSharedByHosts(t *testing.T) {
+	var tokenPolls int
+	handler := externalAuthServer(&tokenPolls)
+	var mu sync.Mutex
+	locked := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		handler(w, r)
+	})
+	ts1 := httptest.NewTLSServer(locked)
+	defer ts1.Close()
+	ts2 := httptest.NewTLSServer(locked)
+	defer ts2.Close()
+
+	var redirects int
+	connector, err := NewConnector(Config{
+		DSN:          "presto://" + strings.TrimPrefix(ts1.URL, "https://") + "," + strings.TrimPrefix(ts2.URL, "https://") + "?ssl=true&host_strategy=round_robin",
+		Client:       ts1.Client(),
+		ExternalAuth: func(string) error { redirects++; return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Successive queries are sent to different coordinators, which accept the same token
+	for i := 0; i < 2; i++ {
+		cn, _ := connector.Connect(context.Background())
+		st, _ := cn.Prepare("SELECT 1")
+		r, err := st.Query(nil)
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		r.Close()
+	}
+	if redirects != 1 {
+		t.Errorf("got %d authentications, wanted 1", redirects)
+	}
+}
+
+func TestExternalAuthRequiresSSL(t *testing.T) {
+	var tokenPolls int
+	ts := httptest.NewServer(externalAuthServer(&tokenPolls))
+	defer ts.Close()
+
+	connector, err := NewConnector(Config{
+		DSN:          "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		ExternalAuth: func(string) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+	st, _ := cn.Prepare("SELECT 1")
+	if _, err := st.Query(nil); !errors.Is(err, ErrExternalAuthFailed) {
+		t.Fatalf("got %v, wanted ErrExternalAuthFailed", err)
+	}
+	if tokenPolls != 0 {
+		t.Errorf("got %d token polls, wanted none over http", tokenPolls)
+	}
+}
@@ -0,0 +1,230 @@
+package prestgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ServerInfo describes a Presto server, as reported by its /v1/info endpoint.
+type ServerInfo struct {
+	Version     string
+	Environment string
+	Coordinator bool
+
+	// Starting is true until the server is ready to accept queries.
+	Starting bool
+
+	Uptime time.Duration
+}
+
+func (si *ServerInfo) UnmarshalJSON(b []byte) error {
+	var v struct {
+		NodeVersion struct {
+			Version string `json:"version"`
+		} `json:"nodeVersion"`
+		Environment string `json:"environment"`
+		Coordinator bool   `json:"coordinator"`
+		Starting    bool   `json:"starting"`
+		Uptime      string `json:"uptime"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	uptime, err := parseServerDuration(v.Uptime)
+	if err != nil {
+		return err
+	}
+	*si = ServerInfo{
+		Version:     v.NodeVersion.Version,
+		Environment: v.Environment,
+		Coordinator: v.Coordinator,
+		Starting:    v.Starting,
+		Uptime:      uptime,
+	}
+	return nil
+}
+
+// ClusterStats summarizes the load on a cluster, as reported by the coordinator's
+// /v1/cluster endpoint.
+type ClusterStats struct {
+	RunningQueries int64 `json:"runningQueries"`
+	BlockedQueries int64 `json:"blockedQueries"`
+	QueuedQueries  int64 `json:"queuedQueries"`
+
+	// ActiveCoordinators is reported by Trino only.
+	ActiveCoordinators int64 `json:"activeCoordinators"`
+	ActiveWorkers      int64 `json:"activeWorkers"`
+	RunningDrivers     int64 `json:"runningDrivers"`
+
+	TotalAvailableProcessors int64   `json:"totalAvailableProcessors"`
+	ReservedMemory           float64 `json:"reservedMemory"` // bytes
+	TotalInputRows           int64   `json:"totalInputRows"`
+	TotalInputBytes          int64   `json:"totalInputBytes"`
+	TotalCPUTimeSecs         int64   `json:"totalCpuTimeSecs"`
+}
+
+// Node describes the health of a worker as seen by the coordinator, reported by its
+// /v1/node endpoint. Request counts are decayed averages so are not whole numbers.
+type Node struct {
+	URI                string        `json:"uri"`
+	RecentRequests     float64       `json:"recentRequests"`
+	RecentFailures     float64       `json:"recentFailures"`
+	RecentSuccesses    float64       `json:"recentSuccesses"`
+	RecentFailureRatio float64       `json:"recentFailureRatio"`
+	LastRequestTime    time.Time     `json:"lastRequestTime"`
+	LastResponseTime   time.Time     `json:"lastResponseTime"`
+	Age                time.Duration `json:"age"`
+}
+
+func (n *Node) UnmarshalJSON(b []byte) error {
+	type plain Node
+	var v struct {
+		plain
+		Age string `json:"age"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	age, err := parseServerDuration(v.Age)
+	if err != nil {
+		return err
+	}
+	*n = Node(v.plain)
+	n.Age = age
+	return nil
+}
+
+// ClusterClient fetches information about the cluster of a data source and manages
+// the queries running on it. It uses the HTTP client and authentication of the Config
+// it was created with and may be used concurrently.
+type ClusterClient struct {
+	connector *Connector
+}
+
+// NewClusterClient returns a ClusterClient for the cluster of the data source named
+// by conf.DSN.
+func NewClusterClient(conf Config) (*ClusterClient, error) {
+	connector, err := NewConnector(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterClient{connector: connector}, nil
+}
+
+// ServerInfo returns information about the coordinator.
+func (cc *ClusterClient) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	var info ServerInfo
+	if err := cc.get(ctx, "/v1/info", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Stats returns the number of queries running and waiting on the cluster and the
+// resources in use.
+func (cc *ClusterClient) Stats(ctx context.Context) (*ClusterStats, error) {
+	var stats ClusterStats
+	if err := cc.get(ctx, "/v1/cluster", &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Nodes returns the workers known to the coordinator.
+func (cc *ClusterClient) Nodes(ctx context.Context) ([]Node, error) {
+	var nodes []Node
+	if err := cc.get(ctx, "/v1/node", &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// FailedNodes returns the workers that the coordinator considers to have failed.
+func (cc *ClusterClient) FailedNodes(ctx context.Context) ([]Node, error) {
+	var nodes []Node
+	if err := cc.get(ctx, "/v1/node/failed", &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// get decodes the response to a request for path from the first available
+// coordinator into v. A new connection is used for each request so that the client
+// may be shared.
+func (cc *ClusterClient) get(ctx context.Context, path string, v interface{}) error {
+	dc, err := cc.connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	c := dc.(*conn)
+	defer c.Close()
+	return c.eachHost(ctx, func(addr string) error {
+		if err := c.resolveProtocol(ctx, addr); err != nil {
+			return err
+		}
+		return c.getJSON(ctx, addr, path, v)
+	})
+}
+
+// serverInfo fetches /v1/info from the server at addr.
+func (c *conn) serverInfo(ctx context.Context, addr string) (*ServerInfo, error) {
+	var info ServerInfo
+	if err := c.getJSON(ctx, addr, "/v1/info", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// getJSON decodes the response to a request for path from the server at addr into v.
+func (c *conn) getJSON(ctx context.Context, addr, path string, v interface{}) error {
+	req, err := http.NewRequest("GET", c.serverURL(addr, path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add(c.header("User"), c.user)
+
+	resp, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return newHTTPError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+var serverDuration = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*(ns|us|ms|s|m|h|d)\s*$`)
+
+var serverDurationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+}
+
+// parseServerDuration parses durations in the form reported by Presto, such as
+// 1.50h or 12.00ms. An empty string is a zero duration.
+func parseServerDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m := serverDuration.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("%s: invalid duration %q", DriverName, s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n * float64(serverDurationUnits[m[2]])), nil
+}
@@ -0,0 +1,116 @@
+package prestgo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var clusterResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Presto-User") == "" {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/v1/info":
+		fmt.Fprint(w, `{"nodeVersion": {"version": "0.280"}, "environment": "production", "coordinator": true, "starting": false, "uptime": "2.50h"}`)
+	case "/v1/cluster":
+		fmt.Fprint(w, `{
+		  "runningQueries": 12, "blockedQueries": 3, "queuedQueries": 40,
+		  "activeWorkers": 8, "runningDrivers": 900, "totalAvailableProcessors": 256,
+		  "reservedMemory": 1.5e10, "totalInputRows": 1000, "totalInputBytes": 2000, "totalCpuTimeSecs": 300
+		}`)
+	case "/v1/node":
+		fmt.Fprint(w, `[{
+		  "uri": "http://worker1:8080", "recentRequests": 120.5, "recentFailures": 0.0, "recentSuccesses": 120.5,
+		  "lastRequestTime": "2020-03-01T12:00:00.000Z", "lastResponseTime": "2020-03-01T12:00:00.050Z",
+		  "recentFailureRatio": 0.0, "age": "3.00d", "recentFailuresByType": {}
+		}]`)
+	case "/v1/node/failed":
+		fmt.Fprint(w, `[{"uri": "http://worker2:8080", "recentFailures": 30.0, "recentFailureRatio": 1.0, "age": "15.00m"}]`)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestClusterClient(t *testing.T) {
+	ts := httptest.NewServer(clusterResponse)
+	defer ts.Close()
+
+	cc, err := NewClusterClient(Config{DSN: "presto://" + strings.TrimPrefix(ts.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	info, err := cc.ServerInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInfo := ServerInfo{Version: "0.280", Environment: "production", Coordinator: true, Uptime: 150 * time.Minute}
+	if *info != expectedInfo {
+		t.Errorf("got info %+v, wanted %+v", *info, expectedInfo)
+	}
+
+	stats, err := cc.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RunningQueries != 12 || stats.BlockedQueries != 3 || stats.QueuedQueries != 40 || stats.ActiveWorkers != 8 || stats.ReservedMemory != 1.5e10 {
+		t.Errorf("got stats %+v", stats)
+	}
+
+	nodes, err := cc.Nodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("got %d nodes, wanted %d", len(nodes), 1)
+	}
+	n := nodes[0]
+	if n.URI != "http://worker1:8080" || n.RecentRequests != 120.5 || n.Age != 72*time.Hour {
+		t.Errorf("got node %+v", n)
+	}
+	if expected := time.Date(2020, 3, 1, 12, 0, 0, 50e6, time.UTC); !n.LastResponseTime.Equal(expected) {
+		t.Errorf("got last response time %v, wanted %v", n.LastResponseTime, expected)
+	}
+
+	failed, err := cc.FailedNodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].URI != "http://worker2:8080" || failed[0].RecentFailureRatio != 1 || failed[0].Age != 15*time.Minute {
+		t.Errorf("got failed nodes %+v", failed)
+	}
+}
+
+func TestParseServerDuration(t *testing.T) {
+	testCases := []struct {
+		s        string
+		expected time.Duration
+		err      bool
+	}{
+		{s: "", expected: 0},
+		{s: "12.00ms", expected: 12 * time.Millisecond},
+		{s: "1.50h", expected: 90 * time.Minute},
+		{s: "2.00d", expected: 48 * time.Hour},
+		{s: "250.00us", expected: 250 * time.Microsecond},
+		{s: "3s", expected: 3 * time.Second},
+		{s: "3 weeks", err: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseServerDuration(tc.s)
+		if tc.err != (err != nil) {
+			t.Errorf("%q: got error %v, wanted %v", tc.s, err, tc.err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("%q: got %v, wanted %v", tc.s, got, tc.expected)
+		}
+	}
+}
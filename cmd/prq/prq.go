@@ -2,17 +2,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"text/tabwriter"
 
-	_ "github.com/avct/prestgo"
+	"github.com/avct/prestgo"
 )
 
-var outformat = flag.String("o", "tabular", "set output format: tabular (default) or tsv")
+var (
+	outformat = flag.String("o", "tabular", "set output format: tabular (default) or tsv")
+	progress  = flag.Bool("progress", false, "show the progress of the query on stderr")
+	kill      = flag.String("kill", "", "kill the query with the given id instead of running a query")
+	message   = flag.String("message", "", "the error message given to a killed query")
+)
 
 func main() {
 	flag.Parse()
@@ -20,15 +27,37 @@ func main() {
 		fatal("missing required data source argument")
 	}
 
+	conf := prestgo.Config{
+		DSN:          flag.Args()[0],
+		ExternalAuth: printRedirect,
+	}
+
+	if *kill != "" {
+		cc, err := prestgo.NewClusterClient(conf)
+		if err != nil {
+			fatal(fmt.Sprintf("failed to connect to presto: %v", err))
+		}
+		if err := cc.KillQuery(context.Background(), *kill, *message); err != nil {
+			fatal(fmt.Sprintf("failed to kill query: %v", err))
+		}
+		return
+	}
+
 	if len(flag.Args()) < 2 {
 		fatal("missing required query argument")
 	}
 
-	db, err := sql.Open("prestgo", flag.Args()[0])
+	connector, err := prestgo.NewConnector(conf)
 	if err != nil {
 		fatal(fmt.Sprintf("failed to connect to presto: %v", err))
 	}
-	rows, err := db.Query(flag.Args()[1])
+	db := sql.OpenDB(connector)
+
+	ctx := context.Background()
+	if *progress {
+		ctx = prestgo.WithProgress(ctx, printProgress)
+	}
+	rows, err := db.QueryContext(ctx, flag.Args()[1])
 	if err != nil {
 		fatal(fmt.Sprintf("failed query presto: %v", err))
 	}
@@ -72,11 +101,28 @@ func main() {
 		}
 		fmt.Fprint(w, "\n")
 	}
+	if *progress {
+		fmt.Fprintln(os.Stderr)
+	}
 	if err := rows.Err(); err != nil {
 		fatal(err.Error())
 	}
 }
 
+// printRedirect asks the user to authenticate with the Presto server.
+func printRedirect(redirectURL string) error {
+	fmt.Fprintf(os.Stderr, "Open the following URL in a browser to authenticate:\n\n\t%s\n\n", redirectURL)
+	return nil
+}
+
+// printProgress draws a progress bar for the query on stderr.
+func printProgress(p prestgo.QueryProgress) {
+	const width = 30
+	pct := p.PercentComplete()
+	done := int(pct / 100 * width)
+	fmt.Fprintf(os.Stderr, "\r%-8s [%s%s] %5.1f%% %d rows %s", p.State, strings.Repeat("=", done), strings.Repeat(" ", width-done), pct, p.ProcessedRows, p.ElapsedTime)
+}
+
 func fatal(msg string) {
 	fmt.Fprintln(os.Stderr, msg)
 	os.Exit(1)
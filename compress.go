@@ -0,0 +1,84 @@
+package prestgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding lists the content encodings the driver can decode, in order of
+// preference. It is sent explicitly so that responses are compressed even when the
+// HTTP client's transport would not ask for compression itself.
+const acceptEncoding = "gzip, deflate"
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decompressBody replaces the body of resp with one that decodes its content
+// encoding. It returns counters of the bytes read from the original body and of the
+// bytes decoded from it, which are the same when the body is not compressed.
+func decompressBody(resp *http.Response) (received, decoded *countingReader, err error) {
+	received = &countingReader{r: resp.Body}
+
+	var body io.Reader
+	switch enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		body = received
+	case "gzip":
+		if body, err = gzip.NewReader(received); err != nil {
+			return nil, nil, err
+		}
+	case "deflate":
+		if body, err = zlib.NewReader(received); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("%s: unsupported content encoding %q", DriverName, enc)
+	}
+
+	decoded = &countingReader{r: body}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{decoded, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.ContentLength = -1
+	return received, decoded, nil
+}
+
+// decodedHTTPError returns an HTTPError describing the unsuccessful response resp,
+// decoding its body when it is compressed. A body that cannot be decoded is reported
+// as it was received.
+func decodedHTTPError(resp *http.Response) *HTTPError {
+	raw, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	herr := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(raw),
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{bytes.NewReader(raw), resp.Body}
+	if _, _, err := decompressBody(resp); err != nil {
+		return herr
+	}
+	if body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody)); err == nil {
+		herr.Body = string(body)
+	}
+	return herr
+}
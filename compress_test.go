@@ -0,0 +1,143 @@
+package prestgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func compressedResponse(encoding string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/statement" {
+			fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+			return
+		}
+
+		body := `{"id": "abcd", "columns": [{"name": "col0", "type": "varchar"}], "data": [["` + strings.Repeat("x", 1000) + `"]], "stats": {"state": "FINISHED"}}`
+		if encoding == "" || !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			fmt.Fprint(w, body)
+			return
+		}
+
+		var buf bytes.Buffer
+		var cw io.WriteCloser
+		if encoding == "gzip" {
+			cw = gzip.NewWriter(&buf)
+		} else {
+			cw = zlib.NewWriter(&buf)
+		}
+		io.WriteString(cw, body)
+		cw.Close()
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(buf.Bytes())
+	}
+}
+
+func TestCompressedResponse(t *testing.T) {
+	testCases := []struct {
+		encoding   string
+		disable    bool
+		compressed bool
+	}{
+		{encoding: "gzip", compressed: true},
+		{encoding: "deflate", compressed: true},
+		{encoding: "gzip", disable: true},
+		{encoding: ""},
+	}
+
+	for _, tc := range testCases {
+		ts := httptest.NewServer(compressedResponse(tc.encoding))
+
+		// The transport must not decompress responses itself
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		connector, err := NewConnector(Config{
+			DSN:                "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+			Client:             client,
+			DisableCompression: tc.disable,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cn, _ := connector.Connect(context.Background())
+		r, err := cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dest := make([]driver.Value, 1)
+		if err := r.Next(dest); err != nil {
+			t.Fatalf("%s: got error %v", tc.encoding, err)
+		}
+		if dest[0] != strings.Repeat("x", 1000) {
+			t.Errorf("%s: got %q", tc.encoding, dest[0])
+		}
+		r.Close()
+
+		stats := r.(StatsProvider).Stats()
+		if stats.DecodedBytes < 1000 {
+			t.Errorf("%s: got %d decoded bytes, wanted at least %d", tc.encoding, stats.DecodedBytes, 1000)
+		}
+		if compressed := stats.ReceivedBytes < stats.DecodedBytes; compressed != tc.compressed {
+			t.Errorf("%s: got %d bytes received and %d decoded, wanted compressed %v", tc.encoding, stats.ReceivedBytes, stats.DecodedBytes, tc.compressed)
+		}
+		ts.Close()
+	}
+}
+
+func TestUnsupportedContentEncoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+
+	r := &rows{conn: &conn{client: http.DefaultClient}, nextURI: ts.URL}
+	if err := r.fetch(); err == nil {
+		t.Errorf("got no error for unsupported content encoding")
+	}
+}
+
+func TestCompressedErrorResponse(t *testing.T) {
+	var gz bytes.Buffer
+	cw := gzip.NewWriter(&gz)
+	io.WriteString(cw, "query not found")
+	cw.Close()
+
+	testCases := []struct {
+		name     string
+		encoding string
+		body     []byte
+		expected string
+	}{
+		{name: "gzip", encoding: "gzip", body: gz.Bytes(), expected: "query not found"},
+		{name: "unsupported", encoding: "br", body: []byte("query not found"), expected: "query not found"},
+		{name: "broken gzip", encoding: "gzip", body: []byte("query not found"), expected: "query not found"},
+	}
+
+	for _, tc := range testCases {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", tc.encoding)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(tc.body)
+		}))
+
+		r := &rows{conn: &conn{client: http.DefaultClient}, nextURI: ts.URL}
+		err := r.fetch()
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			t.Errorf("%s: got %v, wanted an HTTPError", tc.name, err)
+		} else if herr.StatusCode != http.StatusInternalServerError || herr.Body != tc.expected {
+			t.Errorf("%s: got status %d body %q, wanted %d %q", tc.name, herr.StatusCode, herr.Body, http.StatusInternalServerError, tc.expected)
+		}
+		ts.Close()
+	}
+}
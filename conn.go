@@ -2,6 +2,7 @@ package prestgo
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/base64"
@@ -9,10 +10,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -34,6 +38,7 @@ var (
 	ErrNotSupported = errors.New(DriverName + ": not supported")
 
 	// ErrQueryFailed indicates that a network or server failure prevented the driver obtaining a query result.
+	// The QueryError and HTTPError values returned by the driver match it when compared using errors.Is.
 	ErrQueryFailed = errors.New(DriverName + ": query failed")
 
 	// ErrQueryCanceled indicates that a query was canceled before results could be retrieved.
@@ -46,10 +51,18 @@ func init() {
 
 type drv struct{}
 
+var _ driver.DriverContext = &drv{}
+
 func (*drv) Open(name string) (driver.Conn, error) {
 	return Open(name)
 }
 
+// OpenConnector returns a Connector for the data source name, so that the connections
+// of a sql.DB share the state of its coordinators.
+func (*drv) OpenConnector(name string) (driver.Connector, error) {
+	return NewConnector(Config{DSN: name})
+}
+
 // Open creates a connection to the specified data source name which should be
 // of the form "presto://hostname:port/catalog/schema?source=x&session=y". http.DefaultClient will
 // be used for communicating with the Presto server.
@@ -63,31 +76,110 @@ func Open(name string) (driver.Conn, error) {
 func ClientOpen(client *http.Client, name string) (driver.Conn, error) {
 
 	conf := make(config)
-	conf.parseDataSource(name)
+	if err := conf.parseDataSource(name); err != nil {
+		return nil, err
+	}
+	if _, err := parseProtocol(conf["protocol"]); err != nil {
+		return nil, err
+	}
+	if _, err := parseHostStrategy(conf["host_strategy"]); err != nil {
+		return nil, err
+	}
 
-	cn := &conn{
-		client:  client,
-		addr:    conf["addr"],
-		catalog: conf["catalog"],
-		schema:  conf["schema"],
-		user:    conf["user"],
-		source:  conf["source"],
-		session:  conf["session"],
+	return newConn(client, conf, clientHostPool(conf)), nil
+}
+
+// newConn creates a connection to the coordinators in hosts using the data source
+// parameters in conf, which must already have been validated by ClientOpen or
+// NewConnector.
+func newConn(client *http.Client, conf config, hosts *hostPool) *conn {
+	protocol, _ := parseProtocol(conf["protocol"])
+	secure, _ := strconv.ParseBool(conf["ssl"])
+	return &conn{
+		client:   client,
+		protocol: protocol,
+		secure:   secure,
+		hosts:    hosts,
+		catalog:  conf["catalog"],
+		schema:   conf["schema"],
+		user:     conf["user"],
+		source:   conf["source"],
+		session:  splitPairs(conf["session"]),
+
+		clientTags:        splitList(conf["client_tags"]),
+		clientInfo:        conf["client_info"],
+		traceToken:        conf["trace_token"],
+		language:          conf["language"],
+		extraCredentials:  splitPairs(conf["extra_credential"]),
+		resourceEstimates: splitPairs(conf["resource_estimate"]),
 	}
-	return cn, nil
 }
 
 type conn struct {
-	client  *http.Client
-	addr    string
-	catalog string
-	schema  string
-	user    string
-	source  string
-	session string
+	client   *http.Client
+	protocol Protocol
+	secure   bool // whether coordinators are reached using https
+	hosts    *hostPool
+	catalog  string
+	schema   string
+	user     string
+	source   string
+	session  map[string]string
+	prepared map[string]string
+
+	// mu guards the session state, catalog, schema, session and prepared, which is
+	// updated from the responses to queries.
+	mu sync.Mutex
+
+	clientTags        []string
+	clientInfo        string
+	traceToken        string
+	language          string
+	extraCredentials  map[string]string
+	resourceEstimates map[string]string
+
+	pollStrategy PollStrategy
+	retryPolicy  *RetryPolicy
+	prefetch     int
+
+	disableCompression bool
+	minServerVersion   string
+	limits             QueryLimits
+	multiStatement     bool
+
+	spooling             bool
+	segmentDecompressors map[string]Decompressor
+
+	onWarning  WarningHandler
+	onProgress ProgressHandler
+	onStarted  QueryStartedHandler
+
+	logger            Logger
+	tracer            Tracer
+	metrics           Metrics
+	queryLogging      QueryLogging
+	queryLogMaxLength int
+
+	externalAuth        ExternalAuthHandler
+	externalAuthTimeout time.Duration
+	tokens              *tokenCache
+
+	closed bool
+	broken bool // guarded by mu
 }
 
-var _ driver.Conn = &conn{}
+var (
+	_ driver.Conn           = &conn{}
+	_ driver.QueryerContext = &conn{}
+)
+
+// serverURL returns the URL of path on the coordinator at addr.
+func (c *conn) serverURL(addr, path string) string {
+	if c.secure {
+		return "https://" + addr + path
+	}
+	return "http://" + addr + path
+}
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	st := &stmt{
@@ -97,7 +189,16 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	return st, nil
 }
 
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	st := &stmt{
+		conn:  c,
+		query: query,
+	}
+	return st.QueryContext(ctx, args)
+}
+
 func (c *conn) Close() error {
+	c.closed = true
 	return nil
 }
 
@@ -110,7 +211,10 @@ type stmt struct {
 	query string
 }
 
-var _ driver.Stmt = &stmt{}
+var (
+	_ driver.Stmt             = &stmt{}
+	_ driver.StmtQueryContext = &stmt{}
+)
 
 func (s *stmt) Close() error {
 	return nil
@@ -129,189 +233,375 @@ func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 	if len(args) > 0 {
 		return nil, ErrNotSupported
 	}
-	queryURL := fmt.Sprintf("http://%s/v1/statement", s.conn.addr)
+	return s.run(context.Background())
+}
 
-	req, err := http.NewRequest("POST", queryURL, strings.NewReader(s.query))
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, ErrNotSupported
+	}
+	return s.run(ctx)
+}
+
+func (s *stmt) run(ctx context.Context) (driver.Rows, error) {
+	if s.conn.multiStatement {
+		if stmts := SplitStatements(s.query); len(stmts) > 1 {
+			return s.runScript(ctx, stmts)
+		}
+	}
+	r, err := s.runQuery(ctx, s.query)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("X-Presto-User", s.conn.user)
-	req.Header.Add("X-Presto-Catalog", s.conn.catalog)
-	req.Header.Add("X-Presto-Schema", s.conn.schema)
-	if s.conn.source != "" {
-		req.Header.Add("X-Presto-Source", s.conn.source)
-	}
-	if s.conn.session != "" {
-		req.Header.Add("X-Presto-Session", s.conn.session)
+	return r, nil
+}
+
+// runQuery starts a single statement.
+func (s *stmt) runQuery(ctx context.Context, query string) (*rows, error) {
+	started := time.Now()
+	limits := s.conn.queryLimits(ctx)
+	cancel := context.CancelFunc(func() {})
+	if limits.MaxWallTime > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.MaxWallTime)
 	}
 
-	resp, err := s.conn.client.Do(req)
+	rows, err := s.start(ctx, query, started, limits, cancel)
 	if err != nil {
+		cancel()
+		err = wallTimeExceeded("", limits, started, err)
+		s.conn.countStartFailure(err)
+		s.conn.recordFailure(err)
+		s.conn.log(ctx, levelError, "query failed to start", "query", s.conn.logQuery(query), "error", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	// Presto doesn't use the http response code, parse errors come back as 200
-	if resp.StatusCode != 200 {
-		return nil, ErrQueryFailed
-	}
+	return rows, nil
+}
 
-	var sresp stmtResponse
-	err = json.NewDecoder(resp.Body).Decode(&sresp)
+func (s *stmt) start(ctx context.Context, query string, started time.Time, limits QueryLimits, cancel context.CancelFunc) (*rows, error) {
+	var retries int
+	sresp, addr, err := s.submit(ctx, query, &retries)
 	if err != nil {
 		return nil, err
 	}
 
-	if sresp.Stats.State == "FAILED" {
-		return nil, sresp.Error
+	s.conn.count(MetricQueriesStarted, 1)
+	s.conn.log(ctx, levelInfo, "query submitted", "query_id", sresp.ID, "addr", addr, "query", s.conn.logQuery(query), "retries", retries)
+	if s.conn.onStarted != nil && sresp.ID != "" {
+		s.conn.onStarted(sresp.ID, sresp.InfoURI)
 	}
 
 	r := &rows{
 		conn:    s.conn,
+		ctx:     ctx,
+		id:      sresp.ID,
+		infoURI: sresp.InfoURI,
 		nextURI: sresp.NextURI,
+		stats:   QueryStats{Retries: retries},
+		limits:  limits,
+		started: started,
+		release: cancel,
+	}
+	r.addWarnings(sresp.Warnings)
+	r.reportProgress(&sresp.Stats)
+	r.observeQueueTime(&sresp.Stats)
+
+	if sresp.Stats.State == "FAILED" {
+		return nil, queryError(sresp.ID, sresp.Error)
 	}
 
+	r.startTrace(ctx, sresp.Stats.State)
 	return r, nil
 }
 
+// submit sends the query to the server and decodes its response, returning it with
+// the address of the coordinator that accepted the query.
+func (s *stmt) submit(ctx context.Context, query string, retries *int) (sresp *stmtResponse, addr string, err error) {
+	ctx = s.conn.startSpan(ctx, SpanSubmit, SpanInfo{})
+	defer func() {
+		info := SpanInfo{Err: err}
+		if sresp != nil {
+			info.QueryID, info.State = sresp.ID, sresp.Stats.State
+		}
+		s.conn.endSpan(ctx, SpanSubmit, info)
+	}()
+
+	resp, addr, err := s.conn.startQuery(ctx, query, retries)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	// Presto doesn't use the http response code, parse errors come back as 200
+	if resp.StatusCode != 200 {
+		return nil, "", newHTTPError(resp)
+	}
+	s.conn.updateSession(resp.Header)
+
+	sresp = &stmtResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(sresp); err != nil {
+		return nil, "", err
+	}
+	return sresp, addr, nil
+}
+
 type rows struct {
 	conn     *conn
+	ctx      context.Context
+	id       string
+	infoURI  string
 	nextURI  string
 	fetched  bool
 	rowindex int
 	columns  []string
-	types    []driver.ValueConverter
-	data     []queryData
+	decoders []columnDecoder
+	data     [][]driver.Value
+	stats    QueryStats
+	warnings []Warning
+	err      error // error of a fetch made by Columns, returned by Next
+
+	// mu guards stats and warnings, which are updated by the prefetching
+	// goroutine while the caller may be reading them.
+	mu sync.Mutex
+
+	limits    QueryLimits
+	started   time.Time
+	delivered int64
+	dequeued  bool // whether the query's queue time has been observed
+	truncated bool
+	release   context.CancelFunc // releases the context limiting the wall time
+
+	trace rowsTrace // guarded by mu
+
+	// Set when pages are being prefetched
+	pages   chan page
+	pageErr error // error of the last page received, kept once the channel closes
+	cancel  context.CancelFunc
+	closed  bool
 }
 
 var _ driver.Rows = &rows{}
 
 func (r *rows) fetch() error {
-	// TODO: timeout
-	for {
-		qresp, gotData, err := r.waitForData()
+	var qresp *queryResponse
+	var err error
+	if r.conn.prefetch > 0 {
+		qresp, err = r.receivePage()
+	} else {
+		qresp, err = r.nextPage()
+	}
+	if err != nil {
+		err = r.exceeded(wallTimeExceeded(r.id, r.limits, r.started, err))
+		if err != io.EOF {
+			r.traceFailure(err)
+			r.conn.countFailure(err)
+			r.conn.recordFailure(err)
+			r.conn.log(r.context(), levelError, "query failed", "query_id", r.id, "elapsed", time.Since(r.started), "error", err)
+		}
+		return err
+	}
+
+	r.rowindex = 0
+	r.data = qresp.Data
+	r.fetched = true
+
+	if len(qresp.Data) == 0 {
+		return io.EOF
+	}
+
+	return nil
+}
+
+// nextPage polls the query until the next page containing data, or the final page,
+// is available.
+func (r *rows) nextPage() (*queryResponse, error) {
+	strategy := r.conn.pollStrategy
+	if strategy == nil {
+		strategy = DefaultPollStrategy
+	}
+	for empty := 1; ; empty++ {
+		start := time.Now()
+		qresp, gotData, err := r.waitForData(strategy)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if !gotData {
-			time.Sleep(800 * time.Millisecond) // TODO: make this interval configurable
+			wait := pollWait(strategy, empty, time.Since(start))
+			r.conn.observe(MetricPollSleep, wait)
+			if err := sleep(r.context(), wait); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
-		r.rowindex = 0
-		r.data = qresp.Data
-
 		// Note: qresp.Stats.State will be FINISHED when last page is retrieved
 		r.nextURI = qresp.NextURI
-
-		if !r.fetched {
-			r.columns = make([]string, len(qresp.Columns))
-			r.types = make([]driver.ValueConverter, len(qresp.Columns))
-			for i, col := range qresp.Columns {
-				r.columns[i] = col.Name
-				switch {
-				case strings.HasPrefix(col.Type, VarChar):
-					r.types[i] = driver.String
-				case col.Type == BigInt, col.Type == Integer:
-					r.types[i] = bigIntConverter
-				case col.Type == Boolean:
-					r.types[i] = driver.Bool
-				case col.Type == Double:
-					r.types[i] = doubleConverter
-				case col.Type == Timestamp:
-					r.types[i] = timestampConverter
-				case col.Type == TimestampWithTimezone:
-					r.types[i] = timestampWithTimezoneConverter
-				case col.Type == MapVarchar:
-					r.types[i] = mapVarcharConverter
-				case col.Type == VarBinary:
-					r.types[i] = varbinaryConverter
-				case col.Type == ArrayVarchar:
-					r.types[i] = arrayVarcharConverter
-
-				default:
-					return fmt.Errorf("unsupported column type: %s", col.Type)
-				}
-			}
-			r.fetched = true
-		}
-
-		if len(qresp.Data) == 0 {
-			return io.EOF
-		}
-
-		return nil
+		return qresp, nil
 	}
 }
 
-func (r *rows) waitForData() (*queryResponse, bool, error) {
-	nextReq, err := http.NewRequest("GET", r.nextURI, nil)
+func (r *rows) waitForData(strategy PollStrategy) (_ *queryResponse, _ bool, err error) {
+	span := SpanInfo{QueryID: r.id}
+	ctx := r.conn.startSpan(r.context(), SpanFetch, span)
+	defer func() {
+		span.Err = err
+		r.conn.endSpan(ctx, SpanFetch, span)
+	}()
+
+	nextReq, err := http.NewRequest("GET", pageURL(r.nextURI, strategy), nil)
 	if err != nil {
 		return nil, false, err
 	}
+	nextReq = nextReq.WithContext(ctx)
+	nextReq.Header.Add(r.conn.header("User"), r.conn.user)
+	if !r.conn.disableCompression {
+		nextReq.Header.Set("Accept-Encoding", acceptEncoding)
+	}
 
-	nextResp, err := r.conn.client.Do(nextReq)
+	var retries int
+	nextResp, err := r.conn.doRetry(nextReq, true, &retries)
+	r.mu.Lock()
+	r.stats.Retries += retries
+	r.mu.Unlock()
 	if err != nil {
 		return nil, false, err
 	}
 
 	if nextResp.StatusCode != 200 {
+		err := decodedHTTPError(nextResp)
 		nextResp.Body.Close()
-		return nil, false, ErrQueryFailed
+		return nil, false, err
 	}
 
-	var qresp queryResponse
-	err = json.NewDecoder(nextResp.Body).Decode(&qresp)
+	received, decoded, err := decompressBody(nextResp)
+	if err != nil {
+		nextResp.Body.Close()
+		return nil, false, err
+	}
+	r.conn.updateSession(nextResp.Header)
+
+	qresp, err := r.decodeResponse(nextResp.Body)
+	if err == nil {
+		// Read to the end so that a compressed body's checksum is verified
+		_, err = io.Copy(ioutil.Discard, nextResp.Body)
+	}
 	nextResp.Body.Close()
+	r.mu.Lock()
+	r.stats.ReceivedBytes += received.n
+	r.stats.DecodedBytes += decoded.n
+	r.mu.Unlock()
+	span.Bytes = received.n
 	if err != nil {
 		return nil, false, err
 	}
+	if qresp.Spooled != nil {
+		if qresp.Data, err = r.loadSegments(qresp.Spooled); err != nil {
+			return nil, false, err
+		}
+	}
+	r.addWarnings(qresp.Warnings)
+	r.reportProgress(&qresp.Stats)
+	r.conn.log(r.context(), levelDebug, "polled query", "query_id", r.id, "state", qresp.Stats.State, "rows", len(qresp.Data),
+		"processed_rows", qresp.Stats.ProcessedRows, "elapsed", time.Since(r.started))
+	r.conn.count(MetricPagesFetched, 1)
+	r.conn.count(MetricBytesReceived, received.n)
+	r.conn.count(MetricRowsReceived, int64(len(qresp.Data)))
+	r.observeQueueTime(&qresp.Stats)
+	if qresp.NextURI == "" && qresp.Stats.State == QueryStateFinished {
+		r.conn.observe(MetricQueryDuration, time.Since(r.started))
+		r.conn.log(r.context(), levelInfo, "query finished", "query_id", r.id, "processed_rows", qresp.Stats.ProcessedRows, "elapsed", time.Since(r.started))
+	}
+	span.State, span.Rows = qresp.Stats.State, int64(len(qresp.Data))
+	r.traceState(qresp)
+	if err := r.checkPageLimits(qresp); err != nil {
+		return nil, false, err
+	}
 
+	// The query is over on the server, so there is nothing left for Close to cancel
 	switch qresp.Stats.State {
 	case QueryStateFailed:
-		return nil, false, qresp.Error
+		r.nextURI = ""
+		return nil, false, queryError(qresp.ID, qresp.Error)
 	case QueryStateCanceled:
+		r.nextURI = ""
 		return nil, false, ErrQueryCanceled
-	case QueryStatePlanning, QueryStateQueued, QueryStateRunning, QueryStateStarting:
-		if len(qresp.Data) == 0 {
-			r.nextURI = qresp.NextURI
-			return nil, false, nil
-		}
 	}
 
-	return &qresp, true, nil
+	// A page without data is only the last if it has no next page. Servers report
+	// states, such as WAITING_FOR_RESOURCES or FINISHING, that have no constant here.
+	if len(qresp.Data) == 0 && qresp.NextURI != "" {
+		r.nextURI = qresp.NextURI
+		return nil, false, nil
+	}
+
+	return qresp, true, nil
+}
+
+// context returns the context of the query that produced the rows.
+func (r *rows) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
 }
 
 func (r *rows) Columns() []string {
-	if !r.fetched {
+	if !r.fetched && r.err == nil {
 		if err := r.fetch(); err != nil {
+			r.err = err
 			return []string{}
 		}
 	}
 	return r.columns
 }
 
+// Close stops any prefetching and, if the query has not finished, asks the Presto
+// server to cancel it.
 func (r *rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.stopPrefetch()
+	if r.nextURI != "" {
+		r.cancelQuery()
+	}
+	if r.release != nil {
+		r.release()
+	}
+	r.endTrace()
 	return nil
 }
 
 func (r *rows) Next(dest []driver.Value) error {
+	if r.err != nil {
+		return r.err
+	}
 	if !r.fetched || r.rowindex >= len(r.data) {
-		if r.nextURI == "" {
+		if r.pages == nil && r.nextURI == "" {
 			return io.EOF
 		}
+		// A result that is to be truncated ends once it holds MaxRows rows, without
+		// waiting for a page whose rows would be discarded. Otherwise the next page
+		// is needed to tell whether the result exceeds the limit.
+		if r.limits.Truncate {
+			if err := r.checkRowLimit(); err != nil {
+				return r.exceeded(err)
+			}
+		}
 		if err := r.fetch(); err != nil {
 			return err
 		}
 	}
 
-	for i, v := range r.types {
-		val, err := v.ConvertValue(r.data[r.rowindex][i])
-		if err != nil {
-			return err // TODO: more context in error
-		}
-		dest[i] = val
+	if err := r.checkRowLimit(); err != nil {
+		return r.exceeded(err)
 	}
+
+	r.delivered++
+	if r.delivered == 1 {
+		r.conn.observe(MetricTimeToFirstRow, time.Since(r.started))
+	}
+	copy(dest, r.data[r.rowindex])
 	r.rowindex++
 	return nil
 }
@@ -319,10 +609,14 @@ func (r *rows) Next(dest []driver.Value) error {
 type config map[string]string
 
 func (c config) parseDataSource(ds string) error {
+	ds, hostList := cutHosts(ds)
 	u, err := url.Parse(ds)
 	if err != nil {
 		return err
 	}
+	if hostList != "" {
+		u.Host = hostList
+	}
 
 	if u.User != nil {
 		c["user"] = u.User.Username()
@@ -330,16 +624,17 @@ func (c config) parseDataSource(ds string) error {
 		c["user"] = DefaultUsername
 	}
 
-	if strings.IndexRune(u.Host, ':') == -1 {
-		c["addr"] = u.Host + ":" + DefaultPort
-	} else {
-		c["addr"] = u.Host
+	hosts := strings.Split(u.Host, ",")
+	for i, host := range hosts {
+		if strings.IndexRune(host, ':') == -1 {
+			hosts[i] = host + ":" + DefaultPort
+		}
 	}
+	c["addr"] = strings.Join(hosts, ",")
 
 	c["catalog"] = DefaultCatalog
 	c["schema"] = DefaultSchema
 
-
 	pathSegments := strings.FieldsFunc(u.Path, func(c rune) bool { return c == '/' })
 	if len(pathSegments) > 0 {
 		c["catalog"] = pathSegments[0]
@@ -355,6 +650,51 @@ func (c config) parseDataSource(ds string) error {
 	return nil
 }
 
+// cutHosts replaces a comma separated list of hosts in a data source name with a
+// single placeholder, since url.Parse rejects lists in which only some of the hosts
+// have a port. It returns the modified data source name and the list of hosts.
+func cutHosts(ds string) (string, string) {
+	i := strings.Index(ds, "://")
+	if i == -1 {
+		return ds, ""
+	}
+	authority := ds[i+3:]
+	if end := strings.IndexAny(authority, "/?#"); end != -1 {
+		authority = authority[:end]
+	}
+	hosts := authority[strings.LastIndex(authority, "@")+1:]
+	if !strings.Contains(hosts, ",") {
+		return ds, ""
+	}
+	start := i + 3 + len(authority) - len(hosts)
+	return ds[:start] + "hosts" + ds[start+len(hosts):], hosts
+}
+
+// converterForType returns the converter for values of the named column type.
+func converterForType(typ string) (driver.ValueConverter, error) {
+	switch {
+	case strings.HasPrefix(typ, VarChar):
+		return driver.String, nil
+	case typ == BigInt, typ == Integer:
+		return bigIntConverter, nil
+	case typ == Boolean:
+		return driver.Bool, nil
+	case typ == Double:
+		return doubleConverter, nil
+	case typ == Timestamp:
+		return timestampConverter, nil
+	case typ == TimestampWithTimezone:
+		return timestampWithTimezoneConverter, nil
+	case typ == MapVarchar:
+		return mapVarcharConverter, nil
+	case typ == VarBinary:
+		return varbinaryConverter, nil
+	case typ == ArrayVarchar:
+		return arrayVarcharConverter, nil
+	}
+	return nil, fmt.Errorf("unsupported column type: %s", typ)
+}
+
 type valueConverterFunc func(v interface{}) (driver.Value, error)
 
 func (fn valueConverterFunc) ConvertValue(v interface{}) (driver.Value, error) {
@@ -362,20 +702,30 @@ func (fn valueConverterFunc) ConvertValue(v interface{}) (driver.Value, error) {
 }
 
 // bigIntConverter converts a value from the underlying json response into an int64.
-// The Go JSON decoder uses float64 for generic numeric values
+// Numbers are read as json.Number so that large values are not rounded, but float64
+// values produced by the Go JSON decoder are also accepted.
 var bigIntConverter = valueConverterFunc(func(val interface{}) (driver.Value, error) {
 	if val == nil {
 		return nil, nil
 	}
 
-	if vv, ok := val.(float64); ok {
+	switch vv := val.(type) {
+	case float64:
 		return int64(vv), nil
+	case json.Number:
+		if n, err := strconv.ParseInt(string(vv), 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := vv.Float64(); err == nil {
+			return int64(f), nil
+		}
 	}
 	return nil, fmt.Errorf("%s: failed to convert %v (%T) into type int64", DriverName, val, val)
 })
 
-// doubleConverter converts a value from the underlying json response into an int64.
-// The Go JSON decoder uses float64 for generic numeric values
+// doubleConverter converts a value from the underlying json response into a float64.
+// Numbers are read as json.Number, and the strings Infinity and NaN that Presto uses
+// for values JSON cannot represent are also accepted.
 var doubleConverter = valueConverterFunc(func(val interface{}) (driver.Value, error) {
 	if val == nil {
 		return nil, nil
@@ -384,6 +734,10 @@ var doubleConverter = valueConverterFunc(func(val interface{}) (driver.Value, er
 	switch vv := val.(type) {
 	case float64:
 		return vv, nil
+	case json.Number:
+		if f, err := vv.Float64(); err == nil {
+			return f, nil
+		}
 	case string:
 		switch vv {
 		case "Infinity":
@@ -396,6 +750,10 @@ var doubleConverter = valueConverterFunc(func(val interface{}) (driver.Value, er
 	return nil, fmt.Errorf("%s: failed to convert %v (%T) into type float64", DriverName, val, val)
 })
 
+// timestampLayout is used to parse timestamps. It accepts the millisecond precision
+// used by Presto as well as the variable precision of Trino's timestamp(p) types.
+const timestampLayout = "2006-01-02 15:04:05.999999999"
+
 // timestampConverter converts a value from the underlying json response into a time.Time.
 var timestampConverter = valueConverterFunc(func(val interface{}) (driver.Value, error) {
 	if val == nil {
@@ -403,7 +761,7 @@ var timestampConverter = valueConverterFunc(func(val interface{}) (driver.Value,
 	}
 	if vv, ok := val.(string); ok {
 		// BUG: should parse using session time zone.
-		if ts, err := time.ParseInLocation(TimestampFormat, vv, time.Local); err == nil {
+		if ts, err := time.ParseInLocation(timestampLayout, vv, time.Local); err == nil {
 			return ts, nil
 		}
 	}
@@ -416,18 +774,16 @@ var timestampWithTimezoneConverter = valueConverterFunc(func(val interface{}) (d
 		return nil, nil
 	}
 	if vv, ok := val.(string); ok {
-		if len(vv) <= len(TimestampFormat) {
-			return timestampConverter(val)
-		}
-		tzOffset := strings.LastIndex(vv, " ")
-		if tzOffset == -1 {
+		// Values are formatted as date, time and zone separated by spaces
+		parts := strings.SplitN(vv, " ", 3)
+		if len(parts) < 3 {
 			return timestampConverter(val)
 		}
-		tz, err := time.LoadLocation(strings.TrimSpace(vv[tzOffset:]))
+		tz, err := loadZone(strings.TrimSpace(parts[2]))
 		if err != nil {
 			return nil, err
 		}
-		ts, err := time.ParseInLocation(TimestampFormat, vv[:tzOffset], tz)
+		ts, err := time.ParseInLocation(timestampLayout, parts[0]+" "+parts[1], tz)
 		if err != nil {
 			return nil, err
 		}
@@ -436,6 +792,18 @@ var timestampWithTimezoneConverter = valueConverterFunc(func(val interface{}) (d
 	return nil, fmt.Errorf("%s: failed to convert %v (%T) into type time.Time", DriverName, val, val)
 })
 
+// loadZone returns the location for a zone name or, as used by Trino for fixed
+// offset zones, a UTC offset such as +01:00.
+func loadZone(name string) (*time.Location, error) {
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		if offset, err := time.Parse("-07:00", name); err == nil {
+			_, secs := offset.Zone()
+			return time.FixedZone(name, secs), nil
+		}
+	}
+	return time.LoadLocation(name)
+}
+
 // varbinaryConverter converts varbinary to a byte slice
 var varbinaryConverter = valueConverterFunc(func(val interface{}) (driver.Value, error) {
 	if val == nil {
@@ -1,13 +1,17 @@
 package prestgo
 
 import (
+	"bytes"
+	"context"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -416,6 +420,54 @@ func TestRowsNextMultiplePages(t *testing.T) {
 	}
 }
 
+var waitingPageResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/query/abcd/1":
+		fmt.Fprintln(w, fmt.Sprintf(`{
+		  "id": "abcd",
+		  "nextUri": "http://%[1]s/v1/query/abcd/2",
+		  "stats": {"state": "WAITING_FOR_RESOURCES"}
+		}`, r.Host))
+	case "/v1/query/abcd/2":
+		fmt.Fprintln(w, `{
+		  "id": "abcd",
+		  "columns": [
+		    { "name": "col0", "type": "varchar" }
+		  ],
+		  "data": [
+		    [ "c0r0" ]
+		  ],
+		  "stats": {"state": "FINISHED"}
+		}`)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestRowsNextPollsUnknownStates(t *testing.T) {
+	ts := httptest.NewServer(waitingPageResponse)
+	defer ts.Close()
+
+	r := &rows{
+		conn: &conn{
+			client:       http.DefaultClient,
+			pollStrategy: FixedPoll(time.Millisecond),
+		},
+		nextURI: ts.URL + "/v1/query/abcd/1",
+	}
+
+	values := make([]driver.Value, 1)
+	if err := r.Next(values); err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != "c0r0" {
+		t.Errorf("got %v, wanted %v", values[0], "c0r0")
+	}
+	if err := r.Next(values); err != io.EOF {
+		t.Fatalf("got %v, wanted io.EOF", err)
+	}
+}
+
 func TestDoubleConverter(t *testing.T) {
 	testCases := []struct {
 		val      interface{}
@@ -627,6 +679,12 @@ func TestTimestampWithTimezoneConverter(t *testing.T) {
 			err:      false,
 		},
 
+		{
+			val:      "2015-04-23 10:00:08.123456 -05:30",
+			expected: time.Date(2015, 04, 23, 10, 0, 8, int(123456*time.Microsecond), time.FixedZone("-05:30", -19800)),
+			err:      false,
+		},
+
 		{
 			val:      "2015-04-23 10:00:08.123 Nowhere",
 			expected: nil,
@@ -797,3 +855,108 @@ func TestArrayVarcharConverter(t *testing.T) {
 
 	}
 }
+
+// testQuery starts query using a connection configured by conf to a test server that
+// handles requests with h. conf.DSN, if set, holds the path and parameters of the data
+// source, which are added to the server's address. If conf.PollStrategy is nil the
+// server is polled every millisecond. testQuery returns the query's rows and a
+// function that stops the server.
+func testQuery(t *testing.T, ctx context.Context, h http.Handler, conf Config, query string) (driver.Rows, func()) {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	conf.DSN = "presto://" + strings.TrimPrefix(ts.URL, "http://") + conf.DSN
+	if conf.PollStrategy == nil {
+		conf.PollStrategy = FixedPoll(time.Millisecond)
+	}
+	connector, err := NewConnector(conf)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	cn, err := connector.Connect(ctx)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	r, err := cn.(driver.QueryerContext).QueryContext(ctx, query, nil)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	return r, ts.Close
+}
+
+// syntheticPage returns a page of results with the given number of rows of mixed types.
+func syntheticPage(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"id": "abcd", "columns": [
+	  {"name": "col0", "type": "varchar"},
+	  {"name": "col1", "type": "bigint"},
+	  {"name": "col2", "type": "double"},
+	  {"name": "col3", "type": "boolean"},
+	  {"name": "col4", "type": "timestamp"},
+	  {"name": "col5", "type": "array(varchar)"}
+	], "data": [`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `["row%d", %d, %d.5, %v, "2015-02-09 18:26:02.013", ["a", "b"]]`, i, i*1000, i, i%2 == 0)
+	}
+	buf.WriteString(`], "stats": {"state": "FINISHED"}}`)
+	return buf.Bytes()
+}
+
+func benchmarkDecodeResponse(b *testing.B, n int) {
+	page := syntheticPage(n)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &rows{conn: &conn{}}
+		qresp, err := r.decodeResponse(bytes.NewReader(page))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(qresp.Data) != n {
+			b.Fatalf("got %d rows, wanted %d", len(qresp.Data), n)
+		}
+	}
+}
+
+// benchmarkDecodeGeneric decodes pages the way the driver used to: into generic values
+// which are then converted column by column.
+func benchmarkDecodeGeneric(b *testing.B, n int) {
+	page := syntheticPage(n)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var qresp struct {
+			Columns []queryColumn   `json:"columns"`
+			Data    [][]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(page)).Decode(&qresp); err != nil {
+			b.Fatal(err)
+		}
+		convs := make([]driver.ValueConverter, len(qresp.Columns))
+		for i, col := range qresp.Columns {
+			convs[i], _ = converterForType(col.Type)
+		}
+		dest := make([]driver.Value, len(convs))
+		for _, row := range qresp.Data {
+			for i, conv := range convs {
+				v, err := conv.ConvertValue(row[i])
+				if err != nil {
+					b.Fatal(err)
+				}
+				dest[i] = v
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeResponse100(b *testing.B)   { benchmarkDecodeResponse(b, 100) }
+func BenchmarkDecodeResponse10000(b *testing.B) { benchmarkDecodeResponse(b, 10000) }
+func BenchmarkDecodeGeneric100(b *testing.B)    { benchmarkDecodeGeneric(b, 100) }
+func BenchmarkDecodeGeneric10000(b *testing.B)  { benchmarkDecodeGeneric(b, 10000) }
@@ -0,0 +1,228 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"time"
+)
+
+// Config holds the settings used by a Connector. Settings that can be expressed in a
+// data source name are read from DSN, the remaining fields configure behaviour that
+// has no data source equivalent.
+type Config struct {
+	// DSN is a data source name of the form accepted by Open.
+	DSN string
+
+	// Client is the HTTP client used for communicating with the Presto server. If nil,
+	// http.DefaultClient will be used.
+	Client *http.Client
+
+	// Protocol selects the dialect of the client protocol used to talk to the server,
+	// replacing the protocol data source parameter. If empty, the data source parameter
+	// is used and, if that is absent, ProtocolPresto.
+	Protocol Protocol
+
+	// HostStrategy and HostCooldown control how the coordinators of a data source
+	// listing several hosts are chosen, replacing the host_strategy and host_cooldown
+	// data source parameters. If empty, HostFailover and DefaultHostCooldown are used.
+	HostStrategy HostStrategy
+	HostCooldown time.Duration
+
+	// Limits bounds the time and results of every query. It may be overridden for a
+	// single query using WithQueryLimits.
+	Limits QueryLimits
+
+	// MultiStatement, if true, runs a query holding several statements, as split by
+	// SplitStatements, one after another, returning the results of each as a separate
+	// result set. Otherwise the query is sent to the server as a single statement, as
+	// is needed for statements that themselves hold semicolons, such as routines with
+	// a BEGIN ... END body. ExecScript splits scripts whatever the setting.
+	MultiStatement bool
+
+	// MinServerVersion, if set, causes Ping to fail with ErrUnsupportedServerVersion
+	// when the server reports an older version, such as 0.280 for Presto or 435 for
+	// Trino.
+	MinServerVersion string
+
+	// ClientTags, ClientInfo, TraceToken and Language are sent with every query when
+	// set, replacing the client_tags, client_info, trace_token and language data source
+	// parameters. They may be overridden for a single query using WithClientTags,
+	// WithClientInfo, WithTraceToken and WithLanguage.
+	ClientTags []string
+	ClientInfo string
+	TraceToken string
+	Language   string
+
+	// ExtraCredentials and ResourceEstimates are sent with every query, merged with the
+	// extra_credential and resource_estimate data source parameters. They may be
+	// extended for a single query using WithExtraCredentials and WithResourceEstimates.
+	ExtraCredentials  map[string]string
+	ResourceEstimates map[string]string
+
+	// PollStrategy decides how long to wait before requesting more results from a
+	// query that has not yet produced data. If nil, DefaultPollStrategy is used.
+	PollStrategy PollStrategy
+
+	// RetryPolicy controls the resending of requests that fail with a transient error.
+	// If nil, DefaultRetryPolicy is used.
+	RetryPolicy *RetryPolicy
+
+	// PrefetchPages, if greater than zero, enables fetching pages of results in the
+	// background while the caller processes the current page. At most PrefetchPages
+	// pages are held waiting to be read, after which fetching pauses until the caller
+	// catches up. When prefetching, the handlers below may be called from a goroutine
+	// other than the one reading the rows.
+	PrefetchPages int
+
+	// DisableCompression stops the driver asking the server to compress pages of
+	// results. Compressed pages are decoded by the driver, so compression is used even
+	// when the transport of Client has its own DisableCompression setting enabled.
+	DisableCompression bool
+
+	// Spooling asks Trino servers to return results using the spooling protocol, in
+	// which results are divided into segments that are either sent inline or written
+	// to storage for the driver to download. Servers that do not support spooling
+	// return results as usual. It has no effect when the Presto protocol is used.
+	Spooling bool
+
+	// SegmentDecompressors adds support for spooled segments using compressed
+	// encodings, keyed by the name of the compression, such as "zstd" for json+zstd.
+	// Uncompressed json segments are always supported.
+	SegmentDecompressors map[string]Decompressor
+
+	// Logger, if set, receives log messages describing the execution of queries:
+	// their submission, at info level, each poll for results, at debug level, and
+	// retries, cancellations and failures.
+	Logger Logger
+
+	// Tracer, if set, receives the start and end of the operations involved in
+	// running each query, and may supply its trace token.
+	Tracer Tracer
+
+	// Metrics, if set, receives measurements of the queries run, labelled with the
+	// catalog and source of the connection.
+	Metrics Metrics
+
+	// QueryLogging controls how the text of queries is logged. If QueryLogMaxLength
+	// is greater than zero, logged queries are truncated to that many bytes.
+	QueryLogging      QueryLogging
+	QueryLogMaxLength int
+
+	// OnWarning, if set, is called with each distinct warning reported while executing
+	// a query.
+	OnWarning WarningHandler
+
+	// OnProgress, if set, is called with a snapshot of a query's progress each time the
+	// driver polls for results. It may be overridden for a single query using
+	// WithProgress.
+	OnProgress ProgressHandler
+
+	// OnQueryStarted, if set, is called as soon as the Presto server has accepted a
+	// query, with the query's id and info uri.
+	OnQueryStarted QueryStartedHandler
+
+	// ExternalAuth is called with the URL a user must visit when the Presto server
+	// requests OAuth2 external authentication. If nil, queries sent to a server that
+	// requires external authentication will fail. Tokens are only sent over https, so
+	// the data source must set the ssl parameter.
+	ExternalAuth ExternalAuthHandler
+
+	// ExternalAuthTimeout limits how long the driver waits for a token to be issued
+	// after calling ExternalAuth. If zero, DefaultExternalAuthTimeout is used.
+	ExternalAuthTimeout time.Duration
+}
+
+// Connector creates connections to a Presto server using a Config. It may be passed
+// to sql.OpenDB.
+type Connector struct {
+	conf   Config
+	ds     config
+	hosts  *hostPool
+	tokens *tokenCache
+}
+
+var _ driver.Connector = &Connector{}
+
+// NewConnector returns a Connector that opens connections using the supplied Config.
+func NewConnector(conf Config) (*Connector, error) {
+	ds := make(config)
+	if err := ds.parseDataSource(conf.DSN); err != nil {
+		return nil, err
+	}
+	protocol := string(conf.Protocol)
+	if protocol == "" {
+		protocol = ds["protocol"]
+	}
+	if _, err := parseProtocol(protocol); err != nil {
+		return nil, err
+	}
+	hostConf := ds
+	if conf.HostStrategy != "" || conf.HostCooldown != 0 {
+		hostConf = make(config)
+		for k, v := range ds {
+			hostConf[k] = v
+		}
+		if conf.HostStrategy != "" {
+			hostConf["host_strategy"] = string(conf.HostStrategy)
+		}
+		if conf.HostCooldown != 0 {
+			hostConf["host_cooldown"] = conf.HostCooldown.String()
+		}
+	}
+	if _, err := parseHostStrategy(hostConf["host_strategy"]); err != nil {
+		return nil, err
+	}
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+	return &Connector{conf: conf, ds: ds, hosts: newHostPool(hostConf), tokens: &tokenCache{}}, nil
+}
+
+// Connect returns a new connection to the Presto server.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn := newConn(c.conf.Client, c.ds, c.hosts)
+	if c.conf.Protocol != "" {
+		cn.protocol, _ = parseProtocol(string(c.conf.Protocol))
+	}
+	if c.conf.ClientTags != nil {
+		cn.clientTags = c.conf.ClientTags
+	}
+	if c.conf.ClientInfo != "" {
+		cn.clientInfo = c.conf.ClientInfo
+	}
+	if c.conf.TraceToken != "" {
+		cn.traceToken = c.conf.TraceToken
+	}
+	if c.conf.Language != "" {
+		cn.language = c.conf.Language
+	}
+	cn.extraCredentials = mergePairs(cn.extraCredentials, c.conf.ExtraCredentials)
+	cn.resourceEstimates = mergePairs(cn.resourceEstimates, c.conf.ResourceEstimates)
+	cn.pollStrategy = c.conf.PollStrategy
+	cn.retryPolicy = c.conf.RetryPolicy
+	cn.prefetch = c.conf.PrefetchPages
+	cn.disableCompression = c.conf.DisableCompression
+	cn.minServerVersion = c.conf.MinServerVersion
+	cn.limits = c.conf.Limits
+	cn.multiStatement = c.conf.MultiStatement
+	cn.spooling = c.conf.Spooling
+	cn.segmentDecompressors = c.conf.SegmentDecompressors
+	cn.onWarning = c.conf.OnWarning
+	cn.onProgress = c.conf.OnProgress
+	cn.onStarted = c.conf.OnQueryStarted
+	cn.logger = c.conf.Logger
+	cn.tracer = c.conf.Tracer
+	cn.metrics = c.conf.Metrics
+	cn.queryLogging = c.conf.QueryLogging
+	cn.queryLogMaxLength = c.conf.QueryLogMaxLength
+	cn.externalAuth = c.conf.ExternalAuth
+	cn.externalAuthTimeout = c.conf.ExternalAuthTimeout
+	cn.tokens = c.tokens
+	return cn, nil
+}
+
+// Driver returns the prestgo driver.
+func (c *Connector) Driver() driver.Driver {
+	return &drv{}
+}
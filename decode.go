@@ -0,0 +1,249 @@
+package prestgo
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// columnDecoder reads the values of a column from a stream of JSON tokens, converting
+// them directly into the column's Go type.
+type columnDecoder struct {
+	conv driver.ValueConverter
+
+	// nested is true for types such as maps and arrays whose values are JSON
+	// objects or arrays rather than single tokens.
+	nested bool
+}
+
+func newColumnDecoder(typ string) (columnDecoder, error) {
+	conv, err := converterForType(typ)
+	if err != nil {
+		return columnDecoder{}, err
+	}
+	return columnDecoder{
+		conv:   conv,
+		nested: strings.HasPrefix(typ, "map(") || strings.HasPrefix(typ, "array("),
+	}, nil
+}
+
+func (cd columnDecoder) decode(dec *json.Decoder) (driver.Value, error) {
+	if cd.nested {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+		return cd.conv.ConvertValue(v)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.(type) {
+	case nil:
+		return nil, nil
+	case json.Delim:
+		return nil, fmt.Errorf("%s: unexpected %v in column value", DriverName, tok)
+	}
+	return cd.conv.ConvertValue(tok)
+}
+
+// decodeResponse decodes a page of results from body. Rows are converted as they are
+// read rather than being decoded into generic values first. Presto sends the columns
+// of a result before its data, but if they arrive out of order the data is buffered
+// until the columns are known.
+func (r *rows) decodeResponse(body io.Reader) (*queryResponse, error) {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var qresp queryResponse
+	var pending json.RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		switch key {
+		case "columns":
+			if err := dec.Decode(&qresp.Columns); err != nil {
+				return nil, err
+			}
+			if r.decoders == nil {
+				if err := r.setColumns(qresp.Columns); err != nil {
+					return nil, err
+				}
+			}
+		case "data":
+			if r.decoders == nil {
+				if err := dec.Decode(&pending); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := r.decodeData(dec, &qresp); err != nil {
+				return nil, err
+			}
+		default:
+			if err := qresp.decodeField(dec, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	if pending != nil && r.decoders != nil {
+		pdec := json.NewDecoder(bytes.NewReader(pending))
+		pdec.UseNumber()
+		if err := r.decodeData(pdec, &qresp); err != nil {
+			return nil, err
+		}
+	}
+
+	return &qresp, nil
+}
+
+// decodeField decodes the value of the named field of a response, other than its
+// columns and data. Unknown fields are skipped.
+func (q *queryResponse) decodeField(dec *json.Decoder, key string) error {
+	var v interface{}
+	switch key {
+	case "id":
+		v = &q.ID
+	case "infoUri":
+		v = &q.InfoURI
+	case "partialCancelUri":
+		v = &q.PartialCancelURI
+	case "nextUri":
+		v = &q.NextURI
+	case "stats":
+		v = &q.Stats
+	case "error":
+		v = &q.Error
+	case "warnings":
+		v = &q.Warnings
+	case "updateType":
+		v = &q.UpdateType
+	case "updateCount":
+		v = &q.UpdateCount
+	default:
+		v = &json.RawMessage{}
+	}
+	return dec.Decode(v)
+}
+
+// setColumns records the names of the result's columns and prepares decoders for
+// their values.
+func (r *rows) setColumns(cols []queryColumn) error {
+	columns := make([]string, len(cols))
+	decoders := make([]columnDecoder, len(cols))
+	for i, col := range cols {
+		cd, err := newColumnDecoder(r.conn.normalizeType(col.Type))
+		if err != nil {
+			return err
+		}
+		columns[i] = col.Name
+		decoders[i] = cd
+	}
+	r.columns = columns
+	r.decoders = decoders
+	return nil
+}
+
+// rowsPerBlock is the number of rows whose values are allocated together when decoding.
+const rowsPerBlock = 256
+
+// decodeData decodes the data of a response. It is usually an array of rows but
+// when the spooling protocol is used it is an object describing segments of rows,
+// which are loaded separately.
+func (r *rows) decodeData(dec *json.Decoder, q *queryResponse) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case nil:
+		return nil
+	case json.Delim('['):
+		q.Data, err = r.decodeRowValues(dec)
+		return err
+	case json.Delim('{'):
+		q.Spooled, err = decodeSpooledData(dec)
+		return err
+	}
+	return fmt.Errorf("%s: unexpected %v in place of data", DriverName, tok)
+}
+
+// decodeRows decodes a JSON array of rows.
+func (r *rows) decodeRows(dec *json.Decoder) ([][]driver.Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil
+	}
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("%s: unexpected %v in place of data", DriverName, tok)
+	}
+	return r.decodeRowValues(dec)
+}
+
+// decodeRowValues decodes the rows of an array whose opening delimiter has been
+// read. The values of many rows are allocated in a single block to reduce
+// allocations.
+func (r *rows) decodeRowValues(dec *json.Decoder) ([][]driver.Value, error) {
+	n := len(r.decoders)
+	var data [][]driver.Value
+	var block []driver.Value
+	for dec.More() {
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, err
+		}
+		if len(block)+n > cap(block) {
+			block = make([]driver.Value, 0, rowsPerBlock*n)
+		}
+		start := len(block)
+		for _, cd := range r.decoders {
+			v, err := cd.decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			block = append(block, v)
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, fmt.Errorf("%s: row has more than %d columns", DriverName, n)
+		}
+		data = append(data, block[start:len(block):len(block)])
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return fmt.Errorf("%s: expected %v in response, got %v", DriverName, d, tok)
+	}
+	return nil
+}
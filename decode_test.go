@@ -0,0 +1,116 @@
+package prestgo
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected [][]driver.Value
+		err      bool
+	}{
+		{
+			name: "columns before data",
+			body: `{"id": "abcd", "columns": [{"name": "a", "type": "bigint"}, {"name": "b", "type": "varchar"}], "data": [[1, "x"], [2, "y"]], "stats": {"state": "FINISHED"}}`,
+			expected: [][]driver.Value{
+				{int64(1), "x"},
+				{int64(2), "y"},
+			},
+		},
+		{
+			name: "data before columns",
+			body: `{"id": "abcd", "data": [[1, "x"]], "columns": [{"name": "a", "type": "bigint"}, {"name": "b", "type": "varchar"}]}`,
+			expected: [][]driver.Value{
+				{int64(1), "x"},
+			},
+		},
+		{
+			name: "nulls",
+			body: `{"columns": [{"name": "a", "type": "varchar"}, {"name": "b", "type": "boolean"}, {"name": "c", "type": "array(varchar)"}], "data": [[null, null, null]]}`,
+			expected: [][]driver.Value{
+				{nil, nil, nil},
+			},
+		},
+		{
+			name: "large bigint",
+			body: `{"columns": [{"name": "a", "type": "bigint"}], "data": [[9007199254740993]]}`,
+			expected: [][]driver.Value{
+				{int64(9007199254740993)},
+			},
+		},
+		{
+			name: "nested",
+			body: `{"columns": [{"name": "a", "type": "map(varchar,varchar)"}, {"name": "b", "type": "array(varchar)"}, {"name": "c", "type": "double"}], "data": [[{"k": "v"}, ["x", "y"], 1.5]]}`,
+			expected: [][]driver.Value{
+				{map[string]string{"k": "v"}, []string{"x", "y"}, 1.5},
+			},
+		},
+		{
+			name: "no data",
+			body: `{"id": "abcd", "columns": [{"name": "a", "type": "bigint"}], "stats": {"state": "RUNNING"}, "unknown": {"x": [1, 2]}}`,
+		},
+		{
+			name: "too many values",
+			body: `{"columns": [{"name": "a", "type": "bigint"}], "data": [[1, 2]]}`,
+			err:  true,
+		},
+		{
+			name: "too few values",
+			body: `{"columns": [{"name": "a", "type": "bigint"}, {"name": "b", "type": "bigint"}], "data": [[1]]}`,
+			err:  true,
+		},
+		{
+			name: "unsupported type",
+			body: `{"columns": [{"name": "a", "type": "interval day to second"}], "data": []}`,
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		r := &rows{conn: &conn{}}
+		qresp, err := r.decodeResponse(strings.NewReader(tc.body))
+		if tc.err != (err != nil) {
+			t.Errorf("%s: got error %v, wanted %v", tc.name, err, tc.err)
+			continue
+		}
+		if err != nil || tc.expected == nil {
+			continue
+		}
+		if !reflect.DeepEqual(qresp.Data, tc.expected) {
+			t.Errorf("%s: got %#v, wanted %#v", tc.name, qresp.Data, tc.expected)
+		}
+	}
+}
+
+func TestDecodeResponseFields(t *testing.T) {
+	r := &rows{conn: &conn{}}
+	qresp, err := r.decodeResponse(strings.NewReader(`{
+	  "id": "abcd",
+	  "infoUri": "http://example/ui/abcd",
+	  "nextUri": "http://example/v1/query/abcd/2",
+	  "columns": [{"name": "a", "type": "map(varchar,varchar)"}],
+	  "data": [[{"k": "v"}]],
+	  "stats": {"state": "RUNNING", "processedRows": 10},
+	  "warnings": [{"warningCode": {"code": 1, "name": "W"}, "message": "m"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qresp.ID != "abcd" || qresp.InfoURI != "http://example/ui/abcd" || qresp.NextURI != "http://example/v1/query/abcd/2" {
+		t.Errorf("got response %+v", qresp)
+	}
+	if qresp.Stats.State != QueryStateRunning || qresp.Stats.ProcessedRows != 10 || len(qresp.Warnings) != 1 {
+		t.Errorf("got stats %+v and warnings %v", qresp.Stats, qresp.Warnings)
+	}
+	if !reflect.DeepEqual(r.columns, []string{"a"}) {
+		t.Errorf("got columns %v", r.columns)
+	}
+	if expected := map[string]string{"k": "v"}; !reflect.DeepEqual(qresp.Data[0][0], expected) {
+		t.Errorf("got %#v, wanted %#v", qresp.Data[0][0], expected)
+	}
+}
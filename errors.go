@@ -0,0 +1,164 @@
+package prestgo
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Error types reported by Presto in QueryError.ErrorType.
+const (
+	// ErrorTypeUser indicates a problem with the query, such as a syntax error or a
+	// missing table.
+	ErrorTypeUser = "USER_ERROR"
+
+	// ErrorTypeInternal indicates a failure within Presto.
+	ErrorTypeInternal = "INTERNAL_ERROR"
+
+	// ErrorTypeInsufficientResources indicates that the cluster could not provide the
+	// resources needed by the query, for example because it is overloaded.
+	ErrorTypeInsufficientResources = "INSUFFICIENT_RESOURCES"
+
+	// ErrorTypeExternal indicates a failure in a system Presto depends on, such as a
+	// connector's data source.
+	ErrorTypeExternal = "EXTERNAL"
+)
+
+// maxErrorBody limits the amount of a response body captured in an HTTPError.
+const maxErrorBody = 64 << 10
+
+// QueryError is returned when Presto reports that a query failed. It may be matched
+// with errors.Is against ErrQueryFailed or against a QueryError whose non-zero
+// ErrorCode, ErrorName and ErrorType fields are compared with those of the error,
+// for example:
+//
+//	errors.Is(err, &prestgo.QueryError{ErrorName: "SYNTAX_ERROR"})
+//	errors.Is(err, &prestgo.QueryError{ErrorType: prestgo.ErrorTypeInsufficientResources})
+//
+// The failure that caused the error may be reached with errors.As using a
+// *FailureInfo.
+type QueryError struct {
+	QueryID           string        `json:"-"`
+	Message           string        `json:"message"`
+	SQLState          string        `json:"sqlState"`
+	ErrorCode         int           `json:"errorCode"`
+	ErrorName         string        `json:"errorName"`
+	ErrorType         string        `json:"errorType"`
+	Retriable         bool          `json:"retriable"`
+	SemanticErrorCode string        `json:"semanticErrorCode"`
+	ErrorLocation     ErrorLocation `json:"errorLocation"`
+	FailureInfo       FailureInfo   `json:"failureInfo"`
+}
+
+// ErrorLocation identifies the position in a query of the text that caused an error.
+// Lines and columns are numbered from 1 and are zero when unknown.
+type ErrorLocation struct {
+	LineNumber   int `json:"lineNumber"`
+	ColumnNumber int `json:"columnNumber"`
+}
+
+// FailureInfo describes the exception that caused a query to fail in the Presto
+// server. The chain of causes may be followed using errors.Unwrap.
+type FailureInfo struct {
+	Type          string         `json:"type"`
+	Message       string         `json:"message"`
+	Cause         *FailureInfo   `json:"cause"`
+	Suppressed    []FailureInfo  `json:"suppressed"`
+	Stack         []string       `json:"stack"`
+	ErrorLocation *ErrorLocation `json:"errorLocation"`
+}
+
+func (e *QueryError) Error() string {
+	msg := DriverName + ": query failed"
+	if e.ErrorName != "" {
+		msg += ": " + e.ErrorName
+	} else if e.FailureInfo.Type != "" {
+		msg += ": " + e.FailureInfo.Type
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Is reports whether target is ErrQueryFailed or a QueryError whose non-zero
+// ErrorCode, ErrorName and ErrorType fields match those of e.
+func (e *QueryError) Is(target error) bool {
+	if target == ErrQueryFailed {
+		return true
+	}
+	t, ok := target.(*QueryError)
+	if !ok {
+		return false
+	}
+	return (t.ErrorCode == 0 || t.ErrorCode == e.ErrorCode) &&
+		(t.ErrorName == "" || t.ErrorName == e.ErrorName) &&
+		(t.ErrorType == "" || t.ErrorType == e.ErrorType)
+}
+
+// Unwrap returns the failure that caused the error, if Presto reported one.
+func (e *QueryError) Unwrap() error {
+	if e.FailureInfo.Type == "" && e.FailureInfo.Message == "" {
+		return nil
+	}
+	return &e.FailureInfo
+}
+
+func (f *FailureInfo) Error() string {
+	if f.Message == "" {
+		return f.Type
+	}
+	return f.Type + ": " + f.Message
+}
+
+// Unwrap returns the cause of the failure, if any.
+func (f *FailureInfo) Unwrap() error {
+	if f.Cause == nil {
+		return nil
+	}
+	return f.Cause
+}
+
+// HTTPError is returned when the Presto server responds to a request with an
+// unexpected HTTP status. It matches ErrQueryFailed when compared using errors.Is.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+
+	// Body holds the start of the response body, which often describes the problem.
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	msg := DriverName + ": query failed: " + e.Status
+	if body := strings.TrimSpace(e.Body); body != "" {
+		msg += ": " + body
+	}
+	return msg
+}
+
+// Is reports whether target is ErrQueryFailed.
+func (e *HTTPError) Is(target error) bool {
+	return target == ErrQueryFailed
+}
+
+// newHTTPError returns an HTTPError describing resp, consuming its body.
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+	}
+}
+
+// queryError returns the error reported for a failed query, filling in details that
+// are missing from the response.
+func queryError(id string, e *QueryError) *QueryError {
+	if e == nil {
+		e = &QueryError{}
+	}
+	e.QueryID = id
+	return e
+}
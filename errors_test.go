@@ -0,0 +1,128 @@
+package prestgo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var syntaxErrorResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/query/abcd/1":
+		fmt.Fprintf(w, `{
+		  "id": "abcd",
+		  "infoUri": "http://%[1]s/v1/query/abcd",
+		  "stats": {"state": "FAILED"},
+		  "error": {
+		    "message": "line 1:8: mismatched input 'FORM'",
+		    "sqlState": "42000",
+		    "errorCode": 1,
+		    "errorName": "SYNTAX_ERROR",
+		    "errorType": "USER_ERROR",
+		    "errorLocation": {"lineNumber": 1, "columnNumber": 8},
+		    "failureInfo": {
+		      "type": "com.facebook.presto.sql.parser.ParsingException",
+		      "message": "line 1:8: mismatched input 'FORM'",
+		      "cause": {
+		        "type": "org.antlr.v4.runtime.InputMismatchException",
+		        "message": "FORM"
+		      },
+		      "suppressed": [],
+		      "stack": ["com.facebook.presto.sql.parser.SqlParser.invokeParser(SqlParser.java:100)"]
+		    }
+		  }
+		}`, r.Host)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestQueryError(t *testing.T) {
+	ts := httptest.NewServer(syntaxErrorResponse)
+	defer ts.Close()
+
+	r := &rows{
+		conn: &conn{
+			client: http.DefaultClient,
+		},
+		nextURI: ts.URL + "/v1/query/abcd/1",
+	}
+
+	err := r.fetch()
+
+	var qe *QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("got %#v, wanted a *QueryError", err)
+	}
+	if qe.QueryID != "abcd" {
+		t.Errorf("got query id %q, wanted %q", qe.QueryID, "abcd")
+	}
+	if qe.ErrorLocation != (ErrorLocation{LineNumber: 1, ColumnNumber: 8}) {
+		t.Errorf("got location %+v, wanted 1:8", qe.ErrorLocation)
+	}
+	if expected := "prestgo: query failed: SYNTAX_ERROR: line 1:8: mismatched input 'FORM'"; err.Error() != expected {
+		t.Errorf("got %q, wanted %q", err.Error(), expected)
+	}
+
+	testCases := []struct {
+		target   error
+		expected bool
+	}{
+		{target: ErrQueryFailed, expected: true},
+		{target: &QueryError{ErrorName: "SYNTAX_ERROR"}, expected: true},
+		{target: &QueryError{ErrorType: ErrorTypeUser}, expected: true},
+		{target: &QueryError{ErrorType: ErrorTypeUser, ErrorCode: 1}, expected: true},
+		{target: &QueryError{ErrorType: ErrorTypeInsufficientResources}, expected: false},
+		{target: &QueryError{ErrorCode: 2}, expected: false},
+		{target: ErrQueryCanceled, expected: false},
+	}
+	for _, tc := range testCases {
+		if got := errors.Is(err, tc.target); got != tc.expected {
+			t.Errorf("errors.Is(err, %v): got %v, wanted %v", tc.target, got, tc.expected)
+		}
+	}
+
+	var fi *FailureInfo
+	if !errors.As(err, &fi) {
+		t.Fatal("got no FailureInfo in chain")
+	}
+	var causes []string
+	for e := error(fi); e != nil; e = errors.Unwrap(e) {
+		causes = append(causes, e.(*FailureInfo).Type)
+	}
+	if len(causes) != 2 || causes[1] != "org.antlr.v4.runtime.InputMismatchException" {
+		t.Errorf("got causes %v", causes)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Query not found", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	r := &rows{
+		conn: &conn{
+			client: http.DefaultClient,
+		},
+		nextURI: ts.URL + "/v1/query/abcd/1",
+	}
+
+	err := r.fetch()
+	if !errors.Is(err, ErrQueryFailed) {
+		t.Errorf("got %v, wanted ErrQueryFailed", err)
+	}
+
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		t.Fatalf("got %#v, wanted an *HTTPError", err)
+	}
+	if he.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d, wanted %d", he.StatusCode, http.StatusNotFound)
+	}
+	if he.Body != "Query not found\n" {
+		t.Errorf("got body %q, wanted %q", he.Body, "Query not found\n")
+	}
+}
@@ -0,0 +1,177 @@
+package prestgo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	clientTagsKey contextKey = iota
+	clientInfoKey
+	traceTokenKey
+	languageKey
+	extraCredentialsKey
+	resourceEstimatesKey
+	progressKey
+	queryLimitsKey
+)
+
+// WithClientTags returns a copy of ctx that sends tags as the client tags of queries
+// run with it, replacing any tags configured for the connection. Presto uses client
+// tags when selecting a resource group for a query.
+func WithClientTags(ctx context.Context, tags ...string) context.Context {
+	return context.WithValue(ctx, clientTagsKey, tags)
+}
+
+// WithClientInfo returns a copy of ctx that sends info as the client info of queries
+// run with it, replacing any configured for the connection.
+func WithClientInfo(ctx context.Context, info string) context.Context {
+	return context.WithValue(ctx, clientInfoKey, info)
+}
+
+// WithTraceToken returns a copy of ctx that sends token as the trace token of queries
+// run with it, replacing any configured for the connection. The trace token appears
+// in the Presto server's logs.
+func WithTraceToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, traceTokenKey, token)
+}
+
+// WithLanguage returns a copy of ctx that sends lang as the language of queries run
+// with it, replacing any configured for the connection.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey, lang)
+}
+
+// WithExtraCredentials returns a copy of ctx that sends creds as extra credentials
+// with queries run using it. They are merged with, and take precedence over, the extra
+// credentials configured for the connection.
+func WithExtraCredentials(ctx context.Context, creds map[string]string) context.Context {
+	return context.WithValue(ctx, extraCredentialsKey, creds)
+}
+
+// WithResourceEstimates returns a copy of ctx that sends estimates, such as
+// EXECUTION_TIME or PEAK_MEMORY, as resource estimates with queries run using it. They
+// are merged with, and take precedence over, the estimates configured for the
+// connection.
+func WithResourceEstimates(ctx context.Context, estimates map[string]string) context.Context {
+	return context.WithValue(ctx, resourceEstimatesKey, estimates)
+}
+
+// setHeaders adds the headers describing the client and session to a statement
+// request, applying any overrides carried by ctx.
+func (c *conn) setHeaders(ctx context.Context, req *http.Request) {
+	req.Header.Add(c.header("User"), c.user)
+	c.setSessionHeaders(req, limitSessionProperties(c.queryLimits(ctx)))
+	if c.source != "" {
+		req.Header.Add(c.header("Source"), c.source)
+	}
+
+	tags := c.clientTags
+	if v, ok := ctx.Value(clientTagsKey).([]string); ok {
+		tags = v
+	}
+	if len(tags) > 0 {
+		req.Header.Add(c.header("Client-Tags"), strings.Join(tags, ","))
+	}
+
+	info := c.clientInfo
+	if v, ok := ctx.Value(clientInfoKey).(string); ok {
+		info = v
+	}
+	if info != "" {
+		req.Header.Add(c.header("Client-Info"), info)
+	}
+
+	token := c.traceToken
+	if c.tracer != nil {
+		if v := c.tracer.TraceToken(ctx); v != "" {
+			token = v
+		}
+	}
+	if v, ok := ctx.Value(traceTokenKey).(string); ok {
+		token = v
+	}
+	if token != "" {
+		req.Header.Add(c.header("Trace-Token"), token)
+	}
+
+	lang := c.language
+	if v, ok := ctx.Value(languageKey).(string); ok {
+		lang = v
+	}
+	if lang != "" {
+		req.Header.Add(c.header("Language"), lang)
+	}
+
+	creds, _ := ctx.Value(extraCredentialsKey).(map[string]string)
+	creds = mergePairs(c.extraCredentials, creds)
+	for _, k := range sortedKeys(creds) {
+		req.Header.Add(c.header("Extra-Credential"), k+"="+url.QueryEscape(creds[k]))
+	}
+
+	if c.spooling && c.protocol == ProtocolTrino {
+		req.Header.Add(c.header("Query-Data-Encoding"), c.queryDataEncodings())
+	}
+
+	estimates, _ := ctx.Value(resourceEstimatesKey).(map[string]string)
+	estimates = mergePairs(c.resourceEstimates, estimates)
+	for _, k := range sortedKeys(estimates) {
+		req.Header.Add(c.header("Resource-Estimate"), k+"="+estimates[k])
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergePairs returns a map holding the entries of a and b, preferring those of b.
+func mergePairs(a, b map[string]string) map[string]string {
+	if len(b) == 0 {
+		return a
+	}
+	m := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
+
+// splitList splits a comma separated data source parameter into its elements.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// splitPairs splits a comma separated list of name=value pairs, as used by the
+// extra_credential and resource_estimate data source parameters, into a map.
+func splitPairs(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		} else {
+			m[kv[0]] = ""
+		}
+	}
+	return m
+}
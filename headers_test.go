@@ -0,0 +1,66 @@
+package prestgo
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSetHeaders(t *testing.T) {
+	conf := make(config)
+	if err := conf.parseDataSource("presto://name@example/tree/birch?source=leaf&client_tags=a,b&client_info=info&extra_credential=k1=v1&extra_credential=k2=v%202&resource_estimate=PEAK_MEMORY=1GB"); err != nil {
+		t.Fatal(err)
+	}
+	cn := newConn(http.DefaultClient, conf, newHostPool(conf))
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected http.Header
+	}{
+		{
+			name: "connection",
+			ctx:  context.Background(),
+			expected: http.Header{
+				"X-Presto-User":              {"name"},
+				"X-Presto-Catalog":           {"tree"},
+				"X-Presto-Schema":            {"birch"},
+				"X-Presto-Source":            {"leaf"},
+				"X-Presto-Client-Tags":       {"a,b"},
+				"X-Presto-Client-Info":       {"info"},
+				"X-Presto-Extra-Credential":  {"k1=v1", "k2=v+2"},
+				"X-Presto-Resource-Estimate": {"PEAK_MEMORY=1GB"},
+			},
+		},
+		{
+			name: "context",
+			ctx: WithResourceEstimates(
+				WithExtraCredentials(
+					WithTraceToken(
+						WithClientTags(context.Background(), "etl"),
+						"trace"),
+					map[string]string{"k2": "override"}),
+				map[string]string{"EXECUTION_TIME": "10m"}),
+			expected: http.Header{
+				"X-Presto-User":              {"name"},
+				"X-Presto-Catalog":           {"tree"},
+				"X-Presto-Schema":            {"birch"},
+				"X-Presto-Source":            {"leaf"},
+				"X-Presto-Client-Tags":       {"etl"},
+				"X-Presto-Client-Info":       {"info"},
+				"X-Presto-Trace-Token":       {"trace"},
+				"X-Presto-Extra-Credential":  {"k1=v1", "k2=override"},
+				"X-Presto-Resource-Estimate": {"EXECUTION_TIME=10m", "PEAK_MEMORY=1GB"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest("POST", "http://example/v1/statement", nil)
+		cn.setHeaders(tc.ctx, req)
+		if !reflect.DeepEqual(req.Header, tc.expected) {
+			t.Errorf("%s: got %v, wanted %v", tc.name, req.Header, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package prestgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHostCooldown is the time a coordinator that failed to accept a query is
+// passed over in favour of the other coordinators of a data source.
+const DefaultHostCooldown = 30 * time.Second
+
+// HostStrategy decides the order in which the coordinators of a data source with
+// several hosts are tried when sending a query. Coordinators that recently failed
+// are always tried after the others.
+type HostStrategy string
+
+const (
+	// HostFailover tries the coordinators in the order they are listed.
+	HostFailover HostStrategy = "failover"
+
+	// HostRandom tries the coordinators in a random order.
+	HostRandom HostStrategy = "random"
+
+	// HostRoundRobin starts each query with the coordinator after the one that the
+	// previous query started with.
+	HostRoundRobin HostStrategy = "round_robin"
+)
+
+func parseHostStrategy(s string) (HostStrategy, error) {
+	switch HostStrategy(s) {
+	case "", HostFailover:
+		return HostFailover, nil
+	case HostRandom, HostRoundRobin:
+		return HostStrategy(s), nil
+	}
+	return "", fmt.Errorf("%s: unknown host strategy %q", DriverName, s)
+}
+
+// HostAttempt records the failure of a coordinator to accept a query.
+type HostAttempt struct {
+	Addr string
+	Err  error
+}
+
+// HostsError is returned when none of the coordinators of a data source with several
+// hosts accepted a query or responded to a request for information.
+type HostsError struct {
+	Attempts []HostAttempt
+}
+
+func (e *HostsError) Error() string {
+	msgs := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		msgs[i] = a.Addr + ": " + a.Err.Error()
+	}
+	return fmt.Sprintf("%s: no coordinator available: %s", DriverName, strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is ErrQueryFailed.
+func (e *HostsError) Is(target error) bool {
+	return target == ErrQueryFailed
+}
+
+// hostHealth records when coordinators last failed to accept a query, keyed by
+// address.
+type hostHealth struct {
+	mu     sync.Mutex
+	failed map[string]time.Time
+}
+
+func (h *hostHealth) fail(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failed == nil {
+		h.failed = make(map[string]time.Time)
+	}
+	h.failed[addr] = time.Now()
+}
+
+func (h *hostHealth) succeed(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failed, addr)
+}
+
+func (h *hostHealth) cooling(addr string, cooldown time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.failed[addr]
+	return ok && time.Since(t) < cooldown
+}
+
+// hostPool holds the coordinators of a data source and their health. A pool is
+// shared by the connections of a Connector so that round robin ordering and the
+// cooldown of failed coordinators span connections.
+type hostPool struct {
+	addrs    []string
+	strategy HostStrategy
+	cooldown time.Duration
+	next     uint32
+	health   hostHealth
+}
+
+// newHostPool returns a pool for the hosts of a data source, configured by its
+// host_strategy and host_cooldown parameters.
+func newHostPool(conf config) *hostPool {
+	strategy, _ := parseHostStrategy(conf["host_strategy"])
+	cooldown, err := time.ParseDuration(conf["host_cooldown"])
+	if err != nil {
+		cooldown = DefaultHostCooldown
+	}
+	return &hostPool{addrs: splitList(conf["addr"]), strategy: strategy, cooldown: cooldown}
+}
+
+var (
+	clientPoolsMu sync.Mutex
+	clientPools   = make(map[string]*hostPool)
+)
+
+// clientHostPool returns the pool shared by the connections ClientOpen makes to the
+// data source described by conf, which have no Connector to hold it.
+func clientHostPool(conf config) *hostPool {
+	key := conf["addr"] + "|" + conf["host_strategy"] + "|" + conf["host_cooldown"]
+	clientPoolsMu.Lock()
+	defer clientPoolsMu.Unlock()
+	p, ok := clientPools[key]
+	if !ok {
+		p = newHostPool(conf)
+		clientPools[key] = p
+	}
+	return p
+}
+
+// order returns the addresses in the order they should be tried.
+func (p *hostPool) order() []string {
+	n := len(p.addrs)
+	ordered := make([]string, n)
+	switch p.strategy {
+	case HostRandom:
+		for i, j := range random.Perm(n) {
+			ordered[i] = p.addrs[j]
+		}
+	case HostRoundRobin:
+		start := int(atomic.AddUint32(&p.next, 1)-1) % n
+		for i := range ordered {
+			ordered[i] = p.addrs[(start+i)%n]
+		}
+	default:
+		copy(ordered, p.addrs)
+	}
+
+	var healthy, cooling []string
+	for _, addr := range ordered {
+		if p.health.cooling(addr, p.cooldown) {
+			cooling = append(cooling, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// eachHost calls fn with the address of each of the connection's coordinators in
+// turn until it succeeds. Coordinators for which fn fails with a network error or a
+// server error are passed over by later requests until their cooldown has expired.
+// A coordinator that rejects the request with a client error, as a standby does for
+// a query it has never seen, is still available, and if no coordinator succeeds the
+// first such error is returned.
+func (c *conn) eachHost(ctx context.Context, fn func(addr string) error) error {
+	addrs := c.hosts.order()
+	var attempts []HostAttempt
+	var rejected error
+	for _, addr := range addrs {
+		err := fn(addr)
+		if err == nil {
+			c.hosts.health.succeed(addr)
+			return nil
+		}
+		if len(addrs) == 1 || ctx.Err() != nil {
+			return err
+		}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode/100 == 4 {
+			if rejected == nil {
+				rejected = err
+			}
+			continue
+		}
+		c.hosts.health.fail(addr)
+		attempts = append(attempts, HostAttempt{Addr: addr, Err: err})
+	}
+	if rejected != nil {
+		return rejected
+	}
+	return &HostsError{Attempts: attempts}
+}
+
+// startQuery sends a query to the first coordinator that accepts it, returning its
+// response and the address of the coordinator. When the data source has several hosts
+// each is tried once, in place of retrying the request, and those that fail are passed
+// over by later queries until their cooldown has expired.
+func (c *conn) startQuery(ctx context.Context, query string, retries *int) (*http.Response, string, error) {
+	addrs := c.hosts.order()
+	var attempts []HostAttempt
+	for _, addr := range addrs {
+		resp, err := c.postStatement(ctx, addr, query, len(addrs) == 1, retries)
+		if ctx.Err() != nil {
+			return resp, addr, err
+		}
+		if err == nil && !retryable(false, resp, nil) {
+			c.hosts.health.succeed(addr)
+			return resp, addr, nil
+		}
+		// Only fail over when the coordinator can't have started executing the query
+		if len(addrs) == 1 || err != nil && !retryable(false, nil, err) {
+			return resp, addr, err
+		}
+		if err == nil {
+			err = newHTTPError(resp)
+			resp.Body.Close()
+		}
+		c.hosts.health.fail(addr)
+		c.log(ctx, levelWarn, "coordinator unavailable", "addr", addr, "error", err)
+		attempts = append(attempts, HostAttempt{Addr: addr, Err: err})
+	}
+	return nil, "", &HostsError{Attempts: attempts}
+}
+
+func (c *conn) postStatement(ctx context.Context, addr, query string, retry bool, retries *int) (*http.Response, error) {
+	req, err := http.NewRequest("POST", c.serverURL(addr, "/v1/statement"), strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.resolveProtocol(ctx, addr); err != nil {
+		return nil, err
+	}
+	c.setHeaders(ctx, req)
+
+	if !retry {
+		return c.do(req)
+	}
+	return c.doRetry(req, false, retries)
+}
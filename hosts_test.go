@@ -0,0 +1,233 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDataSourceHosts(t *testing.T) {
+	testCases := []struct {
+		ds   string
+		addr string
+	}{
+		{ds: "presto://a/hive", addr: "a:8080"},
+		{ds: "presto://a:9000,b/hive", addr: "a:9000,b:8080"},
+		{ds: "presto://user@a,b:9001,c:9002/hive", addr: "a:8080,b:9001,c:9002"},
+	}
+
+	for _, tc := range testCases {
+		conf := make(config)
+		if err := conf.parseDataSource(tc.ds); err != nil {
+			t.Fatal(err)
+		}
+		if conf["addr"] != tc.addr {
+			t.Errorf("%s: got %q, wanted %q", tc.ds, conf["addr"], tc.addr)
+		}
+	}
+}
+
+func TestHostPoolOrder(t *testing.T) {
+	p := &hostPool{addrs: []string{"order-a:1", "order-b:1", "order-c:1"}, cooldown: time.Minute}
+	if got, expected := p.order(), p.addrs; !reflect.DeepEqual(got, expected) {
+		t.Errorf("failover: got %v, wanted %v", got, expected)
+	}
+
+	p.strategy = HostRoundRobin
+	for _, expected := range [][]string{
+		{"order-a:1", "order-b:1", "order-c:1"},
+		{"order-b:1", "order-c:1", "order-a:1"},
+		{"order-c:1", "order-a:1", "order-b:1"},
+	} {
+		if got := p.order(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("round robin: got %v, wanted %v", got, expected)
+		}
+	}
+
+	p.strategy = HostRandom
+	got := p.order()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, p.addrs) {
+		t.Errorf("random: got %v, wanted a permutation of %v", got, p.addrs)
+	}
+
+	// Hosts in their cooldown are tried last
+	p.health.fail("order-a:1")
+	p.strategy = HostFailover
+	if got, expected := p.order(), []string{"order-b:1", "order-c:1", "order-a:1"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("cooldown: got %v, wanted %v", got, expected)
+	}
+	p.cooldown = 0
+	if got, expected := p.order(), p.addrs; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expired cooldown: got %v, wanted %v", got, expected)
+	}
+}
+
+// deadAddr returns the address of a server that is no longer listening.
+func deadAddr() string {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+	return strings.TrimPrefix(ts.URL, "http://")
+}
+
+func TestHostFailover(t *testing.T) {
+	ts := httptest.NewServer(&pagedServer{pages: 1})
+	defer ts.Close()
+	live := strings.TrimPrefix(ts.URL, "http://")
+	dead := deadAddr()
+
+	connector, err := NewConnector(Config{DSN: "presto://" + dead + "," + live})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+
+	r, err := cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Next(make([]driver.Value, 1)); err != nil {
+		t.Errorf("got error %v", err)
+	}
+	r.Close()
+
+	if !connector.hosts.health.cooling(dead, DefaultHostCooldown) {
+		t.Errorf("failed host %s is not cooling down", dead)
+	}
+	if connector.hosts.health.cooling(live, DefaultHostCooldown) {
+		t.Errorf("live host %s is cooling down", live)
+	}
+}
+
+func TestRoundRobinWithOpenRows(t *testing.T) {
+	servers := []*pagedServer{{pages: 5}, {pages: 5}}
+	var addrs []string
+	for _, s := range servers {
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+		addrs = append(addrs, strings.TrimPrefix(ts.URL, "http://"))
+	}
+
+	connector, err := NewConnector(Config{
+		DSN:           "presto://" + strings.Join(addrs, ","),
+		HostStrategy:  HostRoundRobin,
+		PrefetchPages: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second query goes to the other coordinator while the first is still
+	// fetching pages in the background
+	var all []driver.Rows
+	for range servers {
+		r, err := cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		all = append(all, r)
+	}
+	dest := make([]driver.Value, 1)
+	for i, r := range all {
+		for n := 0; n < 5; n++ {
+			if err := r.Next(dest); err != nil {
+				t.Fatalf("query %d: got error %v", i, err)
+			}
+		}
+	}
+
+	for i, s := range servers {
+		if fetched, _ := s.status(); fetched != s.pages {
+			t.Errorf("coordinator %d: got %d pages fetched, wanted %d", i, fetched, s.pages)
+		}
+	}
+}
+
+func TestHostsAllFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	unavailable := strings.TrimPrefix(ts.URL, "http://")
+	dead := deadAddr()
+
+	connector, err := NewConnector(Config{
+		DSN:          "presto://" + dead + "," + unavailable,
+		HostStrategy: HostRoundRobin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+
+	_, err = cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+	var herr *HostsError
+	if !errors.As(err, &herr) {
+		t.Fatalf("got %v, wanted a HostsError", err)
+	}
+	if len(herr.Attempts) != 2 {
+		t.Fatalf("got %d attempts, wanted %d", len(herr.Attempts), 2)
+	}
+	for _, addr := range []string{dead, unavailable} {
+		if !strings.Contains(err.Error(), addr) {
+			t.Errorf("error %q does not mention %s", err, addr)
+		}
+	}
+	var httpErr *HTTPError
+	if !errors.As(herr.Attempts[0].Err, &httpErr) && !errors.As(herr.Attempts[1].Err, &httpErr) {
+		t.Errorf("got attempts %v, wanted one to fail with an HTTPError", herr.Attempts)
+	}
+	if !errors.Is(err, ErrQueryFailed) {
+		t.Errorf("got %v, wanted it to match ErrQueryFailed", err)
+	}
+}
+
+func TestUnknownHostStrategy(t *testing.T) {
+	if _, err := NewConnector(Config{DSN: "presto://a,b?host_strategy=fastest"}); err == nil {
+		t.Errorf("got no error for unknown host strategy")
+	}
+}
+
+func TestHostPoolSharing(t *testing.T) {
+	ds := "presto://share-a:1,share-b:1/hive"
+	c1, err := ClientOpen(http.DefaultClient, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := ClientOpen(http.DefaultClient, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.(*conn).hosts != c2.(*conn).hosts {
+		t.Errorf("connections opened for the same data source have different host pools")
+	}
+
+	connector1, err := NewConnector(Config{DSN: ds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector2, err := NewConnector(Config{DSN: ds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn1, _ := connector1.Connect(context.Background())
+	cn2, _ := connector1.Connect(context.Background())
+	if cn1.(*conn).hosts != cn2.(*conn).hosts {
+		t.Errorf("connections of a connector have different host pools")
+	}
+	if connector1.hosts == connector2.hosts || connector1.hosts == c1.(*conn).hosts {
+		t.Errorf("connectors share their host pool")
+	}
+}
@@ -0,0 +1,183 @@
+package prestgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrLimitExceeded is matched, using errors.Is, by the LimitError returned when a
+// query exceeds one of its QueryLimits.
+var ErrLimitExceeded = errors.New(DriverName + ": query limit exceeded")
+
+// QueryLimits bounds the time and results of a query. The driver enforces the limits
+// itself, canceling the query on the server and returning a LimitError when one is
+// exceeded. Zero values are unlimited.
+type QueryLimits struct {
+	// MaxWallTime limits the time from sending the query until its last row has
+	// been read.
+	MaxWallTime time.Duration
+
+	// MaxQueueTime limits the time the query waits in the server's queue before it
+	// starts running.
+	MaxQueueTime time.Duration
+
+	// MaxRows limits the number of rows in the query's result.
+	MaxRows int64
+
+	// MaxBytes limits the size of the query's result, measured as the decoded size of
+	// the pages of results received from the server.
+	MaxBytes int64
+
+	// Truncate, if true, ends a result that exceeds MaxRows or MaxBytes early instead
+	// of returning a LimitError. The rows hold at most MaxRows rows, and when MaxBytes
+	// is exceeded the rows of the page that exceeded it are discarded. A result
+	// that holds MaxRows rows ends without fetching further pages, so it is reported
+	// as truncated if the query had not finished, even when no rows remained.
+	// Whether the result was truncated is reported by the rows' TruncationProvider
+	// implementation.
+	Truncate bool
+
+	// ServerMaxRunTime and ServerMaxExecutionTime are sent as the
+	// query_max_run_time and query_max_execution_time session properties, so that
+	// the server limits the query's total time and its time spent running. When set
+	// they replace any value of the property in the connection's session.
+	ServerMaxRunTime       time.Duration
+	ServerMaxExecutionTime time.Duration
+}
+
+// Names of the limits reported in LimitError.Limit.
+const (
+	LimitWallTime  = "wall time"
+	LimitQueueTime = "queue time"
+	LimitRows      = "rows"
+	LimitBytes     = "bytes"
+)
+
+// LimitError is returned when a query exceeds one of its QueryLimits.
+type LimitError struct {
+	QueryID string
+
+	// Limit is the name of the limit that was exceeded, such as LimitWallTime.
+	Limit string
+
+	// Max is the value of the limit, formatted for display.
+	Max string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: query %s exceeded its maximum %s of %s", DriverName, e.QueryID, e.Limit, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// WithQueryLimits returns a copy of ctx that applies limits to queries run with it,
+// replacing any limits configured for the connection.
+func WithQueryLimits(ctx context.Context, limits QueryLimits) context.Context {
+	return context.WithValue(ctx, queryLimitsKey, limits)
+}
+
+// queryLimits returns the limits of a query run with ctx.
+func (c *conn) queryLimits(ctx context.Context) QueryLimits {
+	if limits, ok := ctx.Value(queryLimitsKey).(QueryLimits); ok {
+		return limits
+	}
+	return c.limits
+}
+
+// limitSessionProperties returns the session properties that ask the server to
+// enforce limits.
+func limitSessionProperties(limits QueryLimits) map[string]string {
+	var properties map[string]string
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"query_max_run_time", limits.ServerMaxRunTime},
+		{"query_max_execution_time", limits.ServerMaxExecutionTime},
+	} {
+		if p.d > 0 {
+			if properties == nil {
+				properties = make(map[string]string)
+			}
+			properties[p.name] = strconv.FormatInt(int64(p.d/time.Millisecond), 10) + "ms"
+		}
+	}
+	return properties
+}
+
+// wallTimeExceeded replaces the error caused by the expiry of a query's maximum wall
+// time with a LimitError.
+func wallTimeExceeded(id string, limits QueryLimits, started time.Time, err error) error {
+	if limits.MaxWallTime > 0 && errors.Is(err, context.DeadlineExceeded) && time.Since(started) >= limits.MaxWallTime {
+		return &LimitError{QueryID: id, Limit: LimitWallTime, Max: limits.MaxWallTime.String()}
+	}
+	return err
+}
+
+// checkPageLimits checks the limits that depend on the state of a query reported in a
+// page of results.
+func (r *rows) checkPageLimits(qresp *queryResponse) error {
+	if max := r.limits.MaxQueueTime; max > 0 && (qresp.Stats.Queued || qresp.Stats.State == QueryStateQueued) && time.Since(r.started) > max {
+		return &LimitError{QueryID: r.id, Limit: LimitQueueTime, Max: max.String()}
+	}
+	if max := r.limits.MaxBytes; max > 0 && r.Stats().DecodedBytes > max {
+		return &LimitError{QueryID: r.id, Limit: LimitBytes, Max: strconv.FormatInt(max, 10)}
+	}
+	return nil
+}
+
+// checkRowLimit reports whether the rows already returned leave room for another.
+func (r *rows) checkRowLimit() error {
+	if max := r.limits.MaxRows; max > 0 && r.delivered >= max {
+		return &LimitError{QueryID: r.id, Limit: LimitRows, Max: strconv.FormatInt(max, 10)}
+	}
+	return nil
+}
+
+// TruncationProvider reports whether a result was cut short by a query limit. Like
+// QueryInfo, it is implemented by the driver.Rows returned by the driver's
+// connections. A truncated result otherwise ends as though it were complete.
+type TruncationProvider interface {
+	// Truncated reports whether the result was ended early because it exceeded the
+	// MaxRows or MaxBytes limit of a query run with Truncate set.
+	Truncated() bool
+}
+
+var _ TruncationProvider = &rows{}
+
+func (r *rows) Truncated() bool {
+	return r.truncated
+}
+
+// exceeded stops a query that has exceeded a limit, returning the error the rows
+// should report: the LimitError, or io.EOF if the result is to be truncated. Other
+// errors are returned unchanged.
+func (r *rows) exceeded(err error) error {
+	var lerr *LimitError
+	if !errors.As(err, &lerr) {
+		return err
+	}
+	r.abort()
+	if r.limits.Truncate && (lerr.Limit == LimitRows || lerr.Limit == LimitBytes) {
+		r.truncated = true
+		return io.EOF
+	}
+	return err
+}
+
+// abort stops fetching results and cancels the query on the server after it has
+// exceeded a limit.
+func (r *rows) abort() {
+	r.stopPrefetch()
+	if r.nextURI != "" {
+		r.cancelQuery()
+		r.nextURI = ""
+	}
+}
@@ -0,0 +1,258 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stuckServer serves a query that never produces data, remaining in state.
+type stuckServer struct {
+	state string
+
+	mu       sync.Mutex
+	session  []string
+	canceled bool
+}
+
+func (s *stuckServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/statement":
+		s.session = r.Header["X-Presto-Session"]
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED", "queued": true}}`, r.Host)
+	case r.Method == "DELETE":
+		s.canceled = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "%s", "queued": %v}}`, r.Host, s.state, s.state == QueryStateQueued)
+	}
+}
+
+func (s *stuckServer) wasCanceled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.canceled
+}
+
+func expectLimitError(t *testing.T, err error, limit string) {
+	t.Helper()
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != limit {
+		t.Fatalf("got %v, wanted %s limit error", err, limit)
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got %v, wanted it to match ErrLimitExceeded", err)
+	}
+	if lerr.QueryID != "abcd" {
+		t.Errorf("got query id %q, wanted %q", lerr.QueryID, "abcd")
+	}
+}
+
+func TestTimeLimits(t *testing.T) {
+	testCases := []struct {
+		state  string
+		limits QueryLimits
+		limit  string
+	}{
+		{state: QueryStateRunning, limits: QueryLimits{MaxWallTime: 50 * time.Millisecond}, limit: LimitWallTime},
+		{state: QueryStateQueued, limits: QueryLimits{MaxQueueTime: 50 * time.Millisecond}, limit: LimitQueueTime},
+	}
+
+	for _, tc := range testCases {
+		s := &stuckServer{state: tc.state}
+		r, done := testQuery(t, context.Background(), s, Config{Limits: tc.limits}, "SELECT 1")
+
+		start := time.Now()
+		expectLimitError(t, r.Next(make([]driver.Value, 1)), tc.limit)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: limit took %v to apply", tc.limit, elapsed)
+		}
+		if !s.wasCanceled() {
+			t.Errorf("%s: query was not canceled", tc.limit)
+		}
+		r.Close()
+		done()
+	}
+}
+
+func TestResultLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		limits   QueryLimits
+		prefetch int
+		rows     int
+		limit    string
+	}{
+		{name: "rows", limits: QueryLimits{MaxRows: 3}, rows: 3, limit: LimitRows},
+		{name: "rows prefetched", limits: QueryLimits{MaxRows: 3}, prefetch: 2, rows: 3, limit: LimitRows},
+		{name: "exact rows", limits: QueryLimits{MaxRows: 10}, rows: 10},
+		{name: "bytes", limits: QueryLimits{MaxBytes: 200}, rows: 1, limit: LimitBytes},
+	}
+
+	for _, tc := range testCases {
+		s := &pagedServer{pages: 10}
+		r, done := testQuery(t, context.Background(), s, Config{Limits: tc.limits, PrefetchPages: tc.prefetch}, "SELECT 1")
+
+		var n int
+		var err error
+		for err == nil {
+			if err = r.Next(make([]driver.Value, 1)); err == nil {
+				n++
+			}
+		}
+		if n != tc.rows {
+			t.Errorf("%s: got %d rows, wanted %d", tc.name, n, tc.rows)
+		}
+		if tc.limit == "" {
+			if err != io.EOF {
+				t.Errorf("%s: got %v, wanted %v", tc.name, err, io.EOF)
+			}
+		} else {
+			expectLimitError(t, err, tc.limit)
+			if _, canceled := s.status(); !canceled {
+				t.Errorf("%s: query was not canceled", tc.name)
+			}
+		}
+		r.Close()
+		done()
+	}
+}
+
+func TestContextQueryLimits(t *testing.T) {
+	s := &pagedServer{pages: 10}
+	ctx := WithQueryLimits(context.Background(), QueryLimits{MaxRows: 1})
+	r, done := testQuery(t, ctx, s, Config{Limits: QueryLimits{MaxRows: 5}}, "SELECT 1")
+	defer done()
+	defer r.Close()
+
+	dest := make([]driver.Value, 1)
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	expectLimitError(t, r.Next(dest), LimitRows)
+}
+
+func TestServerLimitSessionProperties(t *testing.T) {
+	s := &stuckServer{state: QueryStateFinished}
+	r, done := testQuery(t, context.Background(), s, Config{DSN: "?session=a=b,query_max_run_time=1h", Limits: QueryLimits{
+		ServerMaxRunTime:       90 * time.Second,
+		ServerMaxExecutionTime: 1500 * time.Millisecond,
+	}}, "SELECT 1")
+	defer done()
+	r.Close()
+
+	// The limits replace the connection's value of the same property
+	expected := []string{"a=b", "query_max_execution_time=1500ms", "query_max_run_time=90000ms"}
+	if !reflect.DeepEqual(s.session, expected) {
+		t.Errorf("got session %v, wanted %v", s.session, expected)
+	}
+}
+
+func TestTruncatedResults(t *testing.T) {
+	testCases := []struct {
+		name      string
+		limits    QueryLimits
+		prefetch  int
+		rows      int
+		truncated bool
+	}{
+		{name: "rows", limits: QueryLimits{MaxRows: 3, Truncate: true}, rows: 3, truncated: true},
+		{name: "rows prefetched", limits: QueryLimits{MaxRows: 3, Truncate: true}, prefetch: 2, rows: 3, truncated: true},
+		{name: "exact rows", limits: QueryLimits{MaxRows: 10, Truncate: true}, rows: 10},
+		{name: "bytes", limits: QueryLimits{MaxBytes: 200, Truncate: true}, rows: 1, truncated: true},
+	}
+
+	for _, tc := range testCases {
+		s := &pagedServer{pages: 10}
+		r, done := testQuery(t, context.Background(), s, Config{Limits: tc.limits, PrefetchPages: tc.prefetch}, "SELECT 1")
+
+		var n int
+		var err error
+		for err == nil {
+			if err = r.Next(make([]driver.Value, 1)); err == nil {
+				n++
+			}
+		}
+		if err != io.EOF {
+			t.Errorf("%s: got %v, wanted %v", tc.name, err, io.EOF)
+		}
+		if n != tc.rows {
+			t.Errorf("%s: got %d rows, wanted %d", tc.name, n, tc.rows)
+		}
+		if truncated := r.(TruncationProvider).Truncated(); truncated != tc.truncated {
+			t.Errorf("%s: got truncated %v, wanted %v", tc.name, truncated, tc.truncated)
+		}
+		if _, canceled := s.status(); canceled != tc.truncated {
+			t.Errorf("%s: got canceled %v, wanted %v", tc.name, canceled, tc.truncated)
+		}
+		r.Close()
+		done()
+	}
+}
+
+func TestTruncatedResultsStopFetching(t *testing.T) {
+	s := &pagedServer{pages: 10}
+	r, done := testQuery(t, context.Background(), s, Config{Limits: QueryLimits{MaxRows: 1, Truncate: true}}, "SELECT 1")
+	defer done()
+	defer r.Close()
+
+	dest := make([]driver.Value, 1)
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Next(dest); err != io.EOF {
+		t.Fatalf("got %v, wanted %v", err, io.EOF)
+	}
+	if fetched, _ := s.status(); fetched != 1 {
+		t.Errorf("got %d pages fetched, wanted 1", fetched)
+	}
+}
+
+func TestLimitErrorsThroughDatabaseSQL(t *testing.T) {
+	testCases := []struct {
+		name   string
+		h      http.Handler
+		limits QueryLimits
+		limit  string
+	}{
+		{name: "queue time", h: &stuckServer{state: QueryStateQueued}, limits: QueryLimits{MaxQueueTime: 50 * time.Millisecond}, limit: LimitQueueTime},
+		{name: "wall time", h: &stuckServer{state: QueryStateRunning}, limits: QueryLimits{MaxWallTime: 50 * time.Millisecond}, limit: LimitWallTime},
+		{name: "bytes", h: &pagedServer{pages: 10}, limits: QueryLimits{MaxBytes: 50}, limit: LimitBytes},
+	}
+
+	for _, tc := range testCases {
+		ts := httptest.NewServer(tc.h)
+		connector, err := NewConnector(Config{
+			DSN:          "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+			Limits:       tc.limits,
+			PollStrategy: FixedPoll(time.Millisecond),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+
+		rows, err := db.Query("SELECT 1")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for rows.Next() {
+		}
+		expectLimitError(t, rows.Err(), tc.limit)
+		rows.Close()
+		db.Close()
+		ts.Close()
+	}
+}
@@ -0,0 +1,112 @@
+package prestgo
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Logger receives structured log messages from the driver. The arguments following
+// the message are alternating keys and values. A *slog.Logger may be used as a
+// Logger.
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// QueryLogging controls how the text of a query is included in log messages.
+type QueryLogging int
+
+const (
+	// LogQueryRedacted logs the text of queries with the contents of string
+	// literals replaced by '?', since they often hold the values of sensitive
+	// columns, and comments removed. It is the default.
+	LogQueryRedacted QueryLogging = iota
+
+	// LogQueryText logs the text of queries unchanged.
+	LogQueryText
+
+	// LogQueryNone omits the text of queries from log messages.
+	LogQueryNone
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// log sends a message to the connection's logger, if it has one.
+func (c *conn) log(ctx context.Context, level logLevel, msg string, args ...interface{}) {
+	if c.logger == nil {
+		return
+	}
+	switch level {
+	case levelDebug:
+		c.logger.DebugContext(ctx, msg, args...)
+	case levelInfo:
+		c.logger.InfoContext(ctx, msg, args...)
+	case levelWarn:
+		c.logger.WarnContext(ctx, msg, args...)
+	default:
+		c.logger.ErrorContext(ctx, msg, args...)
+	}
+}
+
+// logQuery returns the text of query as it should be logged.
+func (c *conn) logQuery(query string) string {
+	switch c.queryLogging {
+	case LogQueryText:
+	case LogQueryNone:
+		return ""
+	default:
+		query = redactLiterals(query)
+	}
+	if max := c.queryLogMaxLength; max > 0 && len(query) > max {
+		// Cut at the start of a character so as not to split it
+		for max > 0 && !utf8.RuneStart(query[max]) {
+			max--
+		}
+		query = query[:max] + "..."
+	}
+	return query
+}
+
+// redactLiterals replaces the contents of the string literals in query with '?' and
+// removes its comments, which may also hold sensitive text. Quoted identifiers are
+// left unchanged.
+func redactLiterals(query string) string {
+	var b strings.Builder
+	var removed bool // whether a comment was removed since the last token written
+	var last byte
+	for i := 0; i < len(query); {
+		kind, n := scanSQL(query[i:])
+		tok := query[i : i+n]
+		i += n
+
+		switch kind {
+		case sqlComment:
+			removed = true
+			continue
+		case sqlSpace:
+			// Collapse the space around a removed comment
+			if removed {
+				continue
+			}
+		case sqlString:
+			tok = "'?'"
+		}
+		if removed && b.Len() > 0 && last != ' ' && last != '\t' && last != '\n' && last != '\r' {
+			b.WriteByte(' ')
+		}
+		removed = false
+		b.WriteString(tok)
+		last = tok[len(tok)-1]
+	}
+	return b.String()
+}
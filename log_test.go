@@ -0,0 +1,163 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// recordingLogger records the messages logged at each level.
+type recordingLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *recordingLogger) record(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, strings.TrimSpace(fmt.Sprintln(append([]interface{}{level, msg}, args...)...)))
+}
+
+func (l *recordingLogger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("DEBUG", msg, args)
+}
+
+func (l *recordingLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("INFO", msg, args)
+}
+
+func (l *recordingLogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("WARN", msg, args)
+}
+
+func (l *recordingLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("ERROR", msg, args)
+}
+
+func (l *recordingLogger) messages(prefix string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var msgs []string
+	for _, m := range l.logs {
+		if strings.HasPrefix(m, prefix) {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+func TestRedactLiterals(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "SELECT 1", expected: "SELECT 1"},
+		{query: "SELECT * FROM t WHERE email = 'a@example.com'", expected: "SELECT * FROM t WHERE email = '?'"},
+		{query: "SELECT 'it''s', 'b'", expected: "SELECT '?', '?'"},
+		{query: `SELECT "it's" FROM t`, expected: `SELECT "it's" FROM t`},
+		{query: "SELECT 1 -- don't\nFROM t", expected: "SELECT 1 FROM t"},
+		{query: "SELECT 1 -- 'c'", expected: "SELECT 1 "},
+		{query: "SELECT /* 'x' */ 'y'", expected: "SELECT '?'"},
+		{query: "SELECT/* 'x' */1", expected: "SELECT 1"},
+		{query: "-- secret\nSELECT 1", expected: "SELECT 1"},
+		{query: "SELECT '-- not a comment'", expected: "SELECT '?'"},
+		{query: "SELECT 'unterminated", expected: "SELECT '?'"},
+	}
+
+	for _, tc := range testCases {
+		if got := redactLiterals(tc.query); got != tc.expected {
+			t.Errorf("%q: got %q, wanted %q", tc.query, got, tc.expected)
+		}
+	}
+}
+
+func TestLogQuery(t *testing.T) {
+	query := "SELECT * FROM t WHERE name = 'secret'"
+	testCases := []struct {
+		logging   QueryLogging
+		query     string
+		maxLength int
+		expected  string
+	}{
+		{logging: LogQueryRedacted, expected: "SELECT * FROM t WHERE name = '?'"},
+		{logging: LogQueryText, expected: query},
+		{logging: LogQueryText, maxLength: 8, expected: "SELECT *..."},
+		{logging: LogQueryText, query: "SELECT 'é'", maxLength: 9, expected: "SELECT '..."},
+		{logging: LogQueryText, query: "SELECT '日本'", maxLength: 12, expected: "SELECT '日..."},
+		{logging: LogQueryNone, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		if tc.query == "" {
+			tc.query = query
+		}
+		c := &conn{queryLogging: tc.logging, queryLogMaxLength: tc.maxLength}
+		got := c.logQuery(tc.query)
+		if got != tc.expected {
+			t.Errorf("%d: got %q, wanted %q", tc.logging, got, tc.expected)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("%d: got invalid UTF-8 %q", tc.logging, got)
+		}
+	}
+}
+
+func TestQueryLogging(t *testing.T) {
+	logger := &recordingLogger{}
+	ts := httptest.NewServer(&pagedServer{pages: 2})
+	defer ts.Close()
+
+	connector, err := NewConnector(Config{
+		DSN:    "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		Logger: logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+	r, err := cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 'secret'", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := make([]driver.Value, 1)
+	for r.Next(dest) == nil {
+	}
+	r.Close()
+
+	info := logger.messages("INFO")
+	if len(info) != 2 || !strings.HasPrefix(info[0], "INFO query submitted query_id abcd addr "+strings.TrimPrefix(ts.URL, "http://")+" query SELECT '?' ") ||
+		!strings.HasPrefix(info[1], "INFO query finished query_id abcd") {
+		t.Errorf("got info messages %q, wanted the query's submission and completion", info)
+	}
+	debug := logger.messages("DEBUG polled query")
+	if len(debug) != 2 || !strings.Contains(debug[0], "state RUNNING rows 1") || !strings.Contains(debug[1], "state FINISHED rows 1") {
+		t.Errorf("got debug messages %q, wanted one for each page", debug)
+	}
+}
+
+func TestQueryFailureLogging(t *testing.T) {
+	logger := &recordingLogger{}
+	s := &stuckServer{state: QueryStateRunning}
+	r, done := testQuery(t, context.Background(), s, Config{Logger: logger, Limits: QueryLimits{MaxRows: 1}, RetryPolicy: &RetryPolicy{}}, "SELECT 1")
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.(*rows).ctx = ctx
+	cancel()
+	if err := r.Next(make([]driver.Value, 1)); err == nil {
+		t.Fatal("got no error, wanted the query to fail")
+	}
+	r.Close()
+
+	if msgs := logger.messages("ERROR query failed query_id abcd"); len(msgs) != 1 {
+		t.Errorf("got %q, wanted the failure to be logged", logger.messages("ERROR"))
+	}
+	if msgs := logger.messages("INFO canceling query query_id abcd"); len(msgs) != 1 {
+		t.Errorf("got %q, wanted the cancellation to be logged", logger.messages("INFO"))
+	}
+}
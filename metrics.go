@@ -0,0 +1,166 @@
+package prestgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Names of the metrics reported to a Metrics sink. Durations are observed in seconds.
+const (
+	// Counters
+	MetricQueriesStarted  = "presto_queries_started_total"
+	MetricQueriesFailed   = "presto_queries_failed_total"
+	MetricQueriesCanceled = "presto_queries_canceled_total"
+	MetricPagesFetched    = "presto_pages_fetched_total"
+	MetricBytesReceived   = "presto_bytes_received_total"
+	MetricRowsReceived    = "presto_rows_received_total"
+	MetricRetries         = "presto_retries_total"
+
+	// Histograms
+	MetricTimeToFirstRow = "presto_time_to_first_row_seconds"
+	MetricQueryDuration  = "presto_query_duration_seconds"
+	MetricQueueTime      = "presto_queue_time_seconds"
+	MetricPollSleep      = "presto_poll_sleep_seconds"
+)
+
+// MetricLabels identifies the queries a measurement applies to.
+type MetricLabels struct {
+	Catalog string
+	Source  string
+}
+
+// Metrics receives measurements of the driver's work, such as the number of queries
+// run and the time they spent queued, so that they can be exported to a monitoring
+// system. Its methods may be called concurrently.
+type Metrics interface {
+	// Count adds n to the counter named name.
+	Count(name string, n int64, labels MetricLabels)
+
+	// Observe records value in the histogram named name.
+	Observe(name string, value float64, labels MetricLabels)
+}
+
+// MemoryMetrics is a Metrics sink that keeps measurements in memory, intended for use
+// in tests. The zero value is ready to use.
+type MemoryMetrics struct {
+	mu           sync.Mutex
+	counters     map[memoryMetric]int64
+	observations map[memoryMetric][]float64
+}
+
+type memoryMetric struct {
+	name   string
+	labels MetricLabels
+}
+
+var _ Metrics = &MemoryMetrics{}
+
+func (m *MemoryMetrics) Count(name string, n int64, labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters == nil {
+		m.counters = make(map[memoryMetric]int64)
+	}
+	m.counters[memoryMetric{name, labels}] += n
+}
+
+func (m *MemoryMetrics) Observe(name string, value float64, labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.observations == nil {
+		m.observations = make(map[memoryMetric][]float64)
+	}
+	key := memoryMetric{name, labels}
+	m.observations[key] = append(m.observations[key], value)
+}
+
+// Counter returns the value of the counter named name with labels.
+func (m *MemoryMetrics) Counter(name string, labels MetricLabels) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[memoryMetric{name, labels}]
+}
+
+// Observations returns the values recorded in the histogram named name with labels,
+// in the order they were observed.
+func (m *MemoryMetrics) Observations(name string, labels MetricLabels) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.observations[memoryMetric{name, labels}]...)
+}
+
+// Reset discards all measurements.
+func (m *MemoryMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters = nil
+	m.observations = nil
+}
+
+// metricLabels returns the labels of measurements of the connection's queries.
+func (c *conn) metricLabels() MetricLabels {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return MetricLabels{Catalog: c.catalog, Source: c.source}
+}
+
+// count adds n to a counter of the connection's metrics sink, if it has one.
+func (c *conn) count(name string, n int64) {
+	if c.metrics != nil {
+		c.metrics.Count(name, n, c.metricLabels())
+	}
+}
+
+// observe records a duration in a histogram of the connection's metrics sink, if it
+// has one.
+func (c *conn) observe(name string, d time.Duration) {
+	if c.metrics != nil {
+		c.metrics.Observe(name, d.Seconds(), c.metricLabels())
+	}
+}
+
+// countFailure counts a query that ended with err. Queries stopped by the driver
+// are counted when they are canceled on the server.
+func (c *conn) countFailure(err error) {
+	switch {
+	case errors.Is(err, ErrQueryCanceled):
+		c.count(MetricQueriesCanceled, 1)
+	case stoppedByDriver(err):
+	default:
+		c.count(MetricQueriesFailed, 1)
+	}
+}
+
+// countStartFailure counts a query that could not be started because of err. A query
+// stopped by the driver while it was being submitted is counted as canceled, since
+// there is no query on the server to cancel.
+func (c *conn) countStartFailure(err error) {
+	if stoppedByDriver(err) {
+		c.count(MetricQueriesCanceled, 1)
+		return
+	}
+	c.countFailure(err)
+}
+
+// stoppedByDriver reports whether err was caused by the driver stopping a query,
+// because the caller canceled it, its deadline expired or it exceeded a limit.
+func stoppedByDriver(err error) bool {
+	var lerr *LimitError
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &lerr)
+}
+
+// observeQueueTime records the time the query spent queued once it has left the
+// queue, using the server's measurement when it reports one.
+func (r *rows) observeQueueTime(stats *stmtStats) {
+	if r.dequeued || stats.Queued || stats.State == QueryStateQueued {
+		return
+	}
+	r.dequeued = true
+	d := time.Since(r.started)
+	if stats.QueuedTimeMillis > 0 {
+		d = time.Duration(stats.QueuedTimeMillis) * time.Millisecond
+	}
+	r.conn.observe(MetricQueueTime, d)
+}
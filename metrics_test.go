@@ -0,0 +1,185 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// metricsConfig configures a query whose metrics are recorded by m and labelled with
+// testLabels. Requests for pages are retried once.
+func metricsConfig(m Metrics) Config {
+	return Config{
+		DSN:         "/hive/web?source=dash",
+		Metrics:     m,
+		RetryPolicy: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+	}
+}
+
+var testLabels = MetricLabels{Catalog: "hive", Source: "dash"}
+
+func TestMetrics(t *testing.T) {
+	// Fail the first request for a page so that it is retried
+	var once sync.Once
+	s := &pagedServer{pages: 3}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failed := false
+		if r.URL.Path != "/v1/statement" {
+			once.Do(func() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				failed = true
+			})
+		}
+		if !failed {
+			s.ServeHTTP(w, r)
+		}
+	})
+
+	m := &MemoryMetrics{}
+	r, done := testQuery(t, context.Background(), h, metricsConfig(m), "SELECT 1")
+	defer done()
+	dest := make([]driver.Value, 1)
+	for r.Next(dest) == nil {
+	}
+	r.Close()
+
+	counters := map[string]int64{
+		MetricQueriesStarted:  1,
+		MetricQueriesFailed:   0,
+		MetricQueriesCanceled: 0,
+		MetricPagesFetched:    3,
+		MetricRowsReceived:    3,
+		MetricRetries:         1,
+	}
+	for name, expected := range counters {
+		if got := m.Counter(name, testLabels); got != expected {
+			t.Errorf("%s: got %d, wanted %d", name, got, expected)
+		}
+	}
+	if got := m.Counter(MetricBytesReceived, testLabels); got == 0 {
+		t.Errorf("%s: got no bytes", MetricBytesReceived)
+	}
+	for _, name := range []string{MetricTimeToFirstRow, MetricQueryDuration, MetricQueueTime} {
+		if got := m.Observations(name, testLabels); len(got) != 1 || got[0] <= 0 {
+			t.Errorf("%s: got %v, wanted a single duration", name, got)
+		}
+	}
+}
+
+func TestCanceledQueryMetrics(t *testing.T) {
+	m := &MemoryMetrics{}
+	s := &stuckServer{state: QueryStateQueued}
+	r, done := testQuery(t, context.Background(), s, metricsConfig(m), "SELECT 1")
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r.(*rows).ctx = ctx
+	if err := r.Next(make([]driver.Value, 1)); err == nil {
+		t.Fatal("got no error, wanted the query to time out")
+	}
+	r.Close()
+
+	if got := m.Counter(MetricQueriesCanceled, testLabels); got != 1 {
+		t.Errorf("got %d canceled queries, wanted 1", got)
+	}
+	if got := m.Counter(MetricQueriesFailed, testLabels); got != 0 {
+		t.Errorf("got %d failed queries, wanted 0", got)
+	}
+	if got := m.Observations(MetricPollSleep, testLabels); len(got) == 0 {
+		t.Errorf("got no poll sleeps, wanted the query to be polled")
+	}
+	if got := m.Observations(MetricQueueTime, testLabels); len(got) != 0 {
+		t.Errorf("got queue times %v, wanted none for a query that never left the queue", got)
+	}
+}
+
+func TestFailedQueryMetrics(t *testing.T) {
+	m := &MemoryMetrics{}
+	r, done := testQuery(t, context.Background(), &scriptServer{}, metricsConfig(m), "FAIL")
+	defer done()
+	if err := r.Next(make([]driver.Value, 1)); err == nil {
+		t.Fatal("got no error, wanted the query to fail")
+	}
+	r.Close()
+
+	if got := m.Counter(MetricQueriesFailed, testLabels); got != 1 {
+		t.Errorf("got %d failed queries, wanted 1", got)
+	}
+	if got := m.Counter(MetricQueriesCanceled, testLabels); got != 0 {
+		t.Errorf("got %d canceled queries, wanted 0", got)
+	}
+}
+
+func TestServerCanceledQueryMetrics(t *testing.T) {
+	m := &MemoryMetrics{}
+	s := &stuckServer{state: QueryStateCanceled}
+	r, done := testQuery(t, context.Background(), s, metricsConfig(m), "SELECT 1")
+	defer done()
+	if err := r.Next(make([]driver.Value, 1)); !errors.Is(err, ErrQueryCanceled) {
+		t.Fatalf("got %v, wanted %v", err, ErrQueryCanceled)
+	}
+	r.Close()
+
+	if got := m.Counter(MetricQueriesCanceled, testLabels); got != 1 {
+		t.Errorf("got %d canceled queries, wanted 1", got)
+	}
+	if s.wasCanceled() {
+		t.Error("got a cancellation request for a query the server had already canceled")
+	}
+}
+
+func TestSubmitCanceledMetrics(t *testing.T) {
+	stop := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stop
+	}))
+	defer ts.Close()
+	defer close(stop)
+
+	m := &MemoryMetrics{}
+	conf := metricsConfig(m)
+	conf.DSN = "presto://" + strings.TrimPrefix(ts.URL, "http://") + conf.DSN
+	connector, err := NewConnector(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cn.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil); err == nil {
+		t.Fatal("got no error, wanted the submission to time out")
+	}
+
+	if got := m.Counter(MetricQueriesCanceled, testLabels); got != 1 {
+		t.Errorf("got %d canceled queries, wanted 1", got)
+	}
+	if got := m.Counter(MetricQueriesFailed, testLabels); got != 0 {
+		t.Errorf("got %d failed queries, wanted 0", got)
+	}
+}
+
+func TestSpooledBytesMetrics(t *testing.T) {
+	m := &MemoryMetrics{}
+	conf := metricsConfig(m)
+	conf.Protocol = ProtocolTrino
+	conf.Spooling = true
+	r, done := testQuery(t, context.Background(), &spoolingServer{}, conf, "SELECT 1")
+	defer done()
+	dest := make([]driver.Value, 2)
+	for r.Next(dest) == nil {
+	}
+	r.Close()
+
+	received := r.(StatsProvider).Stats().ReceivedBytes
+	if got := m.Counter(MetricBytesReceived, testLabels); got != received {
+		t.Errorf("got %d bytes received, wanted %d", got, received)
+	}
+}
@@ -0,0 +1,117 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrServerStarting is returned by Ping when the server has not finished starting
+	// and is not yet accepting queries.
+	ErrServerStarting = errors.New(DriverName + ": server is starting")
+
+	// ErrUnsupportedServerVersion is returned by Ping when the server is older than
+	// the minimum version configured for the connection.
+	ErrUnsupportedServerVersion = errors.New(DriverName + ": unsupported server version")
+)
+
+var (
+	_ driver.Pinger    = &conn{}
+	_ driver.Validator = &conn{}
+)
+
+// Ping checks that the server is running and ready to accept queries, using its
+// /v1/info endpoint. When the data source has several hosts, Ping succeeds if any
+// of them is ready.
+func (c *conn) Ping(ctx context.Context) error {
+	err := c.eachHost(ctx, func(addr string) error {
+		return c.ping(ctx, addr)
+	})
+	c.recordFailure(err)
+	return err
+}
+
+func (c *conn) ping(ctx context.Context, addr string) error {
+	info, err := c.serverInfo(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if info.Starting {
+		return ErrServerStarting
+	}
+	version := info.Version
+	if c.minServerVersion != "" && compareVersions(version, c.minServerVersion) < 0 {
+		return fmt.Errorf("%w: %s is older than %s", ErrUnsupportedServerVersion, version, c.minServerVersion)
+	}
+	if c.protocol == ProtocolAuto {
+		c.protocol = protocolForVersion(version)
+	}
+	return nil
+}
+
+// IsValid reports whether the connection may be reused. It is called by database/sql
+// before returning a connection to its pool. A connection is not reused once it has
+// been closed, has failed to reach the server or has failed to authenticate.
+func (c *conn) IsValid() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.closed && !c.broken
+}
+
+// recordFailure marks the connection as broken if err shows that it failed to reach
+// the server or to authenticate, rather than that a query failed.
+func (c *conn) recordFailure(err error) {
+	var hostsErr *HostsError
+	var netErr net.Error
+	if errors.Is(err, ErrExternalAuthFailed) || errors.As(err, &hostsErr) || errors.As(err, &netErr) && !stoppedByDriver(err) {
+		c.mu.Lock()
+		c.broken = true
+		c.mu.Unlock()
+	}
+}
+
+// compareVersions compares the leading dotted numbers of two server versions, such
+// as 0.280 or 435-e.1, returning -1, 0 or 1. Missing components are treated as zero.
+func compareVersions(a, b string) int {
+	va, vb := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionNumbers(version string) []int {
+	var nums []int
+	for _, part := range strings.Split(version, ".") {
+		end := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		if end == -1 {
+			end = len(part)
+		}
+		n, err := strconv.Atoi(part[:end])
+		if err != nil {
+			break
+		}
+		nums = append(nums, n)
+		if end < len(part) {
+			break
+		}
+	}
+	return nums
+}
@@ -0,0 +1,173 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func infoServer(version string, starting bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/info" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"nodeVersion": {"version": "%s"}, "environment": "test", "coordinator": true, "starting": %v, "uptime": "1.00m"}`, version, starting)
+	}))
+}
+
+func TestPing(t *testing.T) {
+	testCases := []struct {
+		name       string
+		version    string
+		starting   bool
+		minVersion string
+		err        error
+	}{
+		{name: "ready", version: "0.280"},
+		{name: "starting", version: "0.280", starting: true, err: ErrServerStarting},
+		{name: "new enough", version: "435-e.1", minVersion: "400"},
+		{name: "too old", version: "0.215", minVersion: "0.280", err: ErrUnsupportedServerVersion},
+	}
+
+	for _, tc := range testCases {
+		ts := infoServer(tc.version, tc.starting)
+		connector, err := NewConnector(Config{
+			DSN:              "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+			MinServerVersion: tc.minVersion,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+
+		err = db.PingContext(context.Background())
+		if tc.err == nil && err != nil {
+			t.Errorf("%s: got error %v", tc.name, err)
+		}
+		if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("%s: got %v, wanted %v", tc.name, err, tc.err)
+		}
+		db.Close()
+		ts.Close()
+	}
+}
+
+func TestPingUnavailable(t *testing.T) {
+	db := sql.OpenDB(mustConnector(t, "presto://"+deadAddr()))
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err == nil {
+		t.Errorf("got no error pinging a server that is down")
+	}
+
+	ts := infoServer("0.280", false)
+	defer ts.Close()
+	db = sql.OpenDB(mustConnector(t, "presto://"+deadAddr()+","+strings.TrimPrefix(ts.URL, "http://")))
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Errorf("got error %v, wanted ping to fail over", err)
+	}
+}
+
+func TestPingResolvesProtocol(t *testing.T) {
+	ts := infoServer("435", false)
+	defer ts.Close()
+
+	conf := config{"addr": strings.TrimPrefix(ts.URL, "http://"), "protocol": "auto"}
+	c := newConn(http.DefaultClient, conf, newHostPool(conf))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.protocol != ProtocolTrino {
+		t.Errorf("got protocol %v, wanted %v", c.protocol, ProtocolTrino)
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	c := &conn{}
+	if !c.IsValid() {
+		t.Errorf("new connection is not valid")
+	}
+	c.Close()
+	if c.IsValid() {
+		t.Errorf("closed connection is valid")
+	}
+}
+
+func TestIsValidAfterFailure(t *testing.T) {
+	testCases := []struct {
+		err   error
+		valid bool
+	}{
+		{err: &QueryError{Message: "failed"}, valid: true},
+		{err: &HTTPError{StatusCode: http.StatusNotFound}, valid: true},
+		{err: context.Canceled, valid: true},
+		{err: fmt.Errorf("%w: access denied", ErrExternalAuthFailed), valid: false},
+		{err: &HostsError{}, valid: false},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &conn{}
+		c.recordFailure(tc.err)
+		if valid := c.IsValid(); valid != tc.valid {
+			t.Errorf("%v: got valid %v, wanted %v", tc.err, valid, tc.valid)
+		}
+	}
+}
+
+func TestIsValidUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	connector, err := NewConnector(Config{
+		DSN:         "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		RetryPolicy: &RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+	if _, err := cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil); err == nil {
+		t.Fatal("got no error, wanted the coordinator to be unreachable")
+	}
+	if cn.(driver.Validator).IsValid() {
+		t.Errorf("connection is valid after failing to reach the coordinator")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"0.280", "0.280", 0},
+		{"0.215", "0.280", -1},
+		{"0.280", "0.28", 1},
+		{"351", "0.280", 1},
+		{"435-e.1", "435", 0},
+		{"0.280-edge1", "0.281", -1},
+		{"1.2", "1.2.0", 0},
+	}
+
+	for _, tc := range testCases {
+		if got := compareVersions(tc.a, tc.b); got != tc.expected {
+			t.Errorf("%s vs %s: got %d, wanted %d", tc.a, tc.b, got, tc.expected)
+		}
+	}
+}
+
+func mustConnector(t *testing.T, dsn string) *Connector {
+	connector, err := NewConnector(Config{DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return connector
+}
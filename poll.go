@@ -0,0 +1,108 @@
+package prestgo
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// DefaultPollStrategy is used when no PollStrategy has been configured. It retries
+// quickly so that short queries are not delayed, backing off for longer ones.
+var DefaultPollStrategy = ExponentialPoll(50*time.Millisecond, 800*time.Millisecond)
+
+// PollStrategy decides how long the driver waits before requesting the next page of
+// results when the server has returned a page without data, such as while a query is
+// queued or running.
+type PollStrategy interface {
+	// Wait returns the time to wait after the nth consecutive page without data,
+	// counting from 1.
+	Wait(n int) time.Duration
+}
+
+// PollStrategyFunc is an adapter that allows a function to be used as a PollStrategy.
+type PollStrategyFunc func(n int) time.Duration
+
+// Wait returns fn(n).
+func (fn PollStrategyFunc) Wait(n int) time.Duration {
+	return fn(n)
+}
+
+// FixedPoll returns a PollStrategy that always waits for interval.
+func FixedPoll(interval time.Duration) PollStrategy {
+	return PollStrategyFunc(func(int) time.Duration {
+		return interval
+	})
+}
+
+// ExponentialPoll returns a PollStrategy that waits for initial after the first page
+// without data, doubling the wait for each subsequent page up to max.
+func ExponentialPoll(initial, max time.Duration) PollStrategy {
+	return PollStrategyFunc(func(n int) time.Duration {
+		wait := initial
+		for i := 1; i < n && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			wait = max
+		}
+		return wait
+	})
+}
+
+// LongPoll returns a PollStrategy that requests the next page immediately, asking the
+// server to hold the request open for up to maxWait until data is available. It
+// relies on the maxWait parameter supported by the statement resource of modern
+// coordinators. When a page without data is returned in less than half of maxWait,
+// as it is by servers that ignore or cap the parameter, the driver waits as it would
+// with DefaultPollStrategy rather than polling in a tight loop.
+func LongPoll(maxWait time.Duration) PollStrategy {
+	return longPoll(maxWait)
+}
+
+type longPoll time.Duration
+
+func (longPoll) Wait(int) time.Duration {
+	return 0
+}
+
+// pollWait returns the time to wait after the nth consecutive page without data,
+// which took elapsed to fetch.
+func pollWait(strategy PollStrategy, n int, elapsed time.Duration) time.Duration {
+	if lp, ok := strategy.(longPoll); ok && (lp <= 0 || elapsed < time.Duration(lp)/2) {
+		return DefaultPollStrategy.Wait(n)
+	}
+	return strategy.Wait(n)
+}
+
+// pageURL returns the uri of the next page of results, adding the maxWait parameter
+// when long polling.
+func pageURL(uri string, strategy PollStrategy) string {
+	lp, ok := strategy.(longPoll)
+	if !ok || lp <= 0 {
+		return uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	q.Set("maxWait", fmt.Sprintf("%dms", time.Duration(lp)/time.Millisecond))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
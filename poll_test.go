@@ -0,0 +1,115 @@
+package prestgo
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExponentialPoll(t *testing.T) {
+	p := ExponentialPoll(10*time.Millisecond, 50*time.Millisecond)
+
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, want := range expected {
+		if got := p.Wait(i + 1); got != want {
+			t.Errorf("wait %d: got %v, wanted %v", i+1, got, want)
+		}
+	}
+}
+
+func TestPollWait(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy PollStrategy
+		elapsed  time.Duration
+		expected time.Duration
+	}{
+		{name: "fixed", strategy: FixedPoll(time.Second), expected: time.Second},
+		{name: "long poll held", strategy: LongPoll(time.Second), elapsed: time.Second, expected: 0},
+		{name: "long poll ignored", strategy: LongPoll(time.Second), elapsed: time.Millisecond, expected: DefaultPollStrategy.Wait(3)},
+		{name: "long poll without wait", strategy: LongPoll(0), elapsed: time.Second, expected: DefaultPollStrategy.Wait(3)},
+	}
+
+	for _, tc := range testCases {
+		if got := pollWait(tc.strategy, 3, tc.elapsed); got != tc.expected {
+			t.Errorf("%s: got %v, wanted %v", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	testCases := []struct {
+		uri      string
+		strategy PollStrategy
+		expected string
+	}{
+		{
+			uri:      "http://example/v1/statement/abcd/1",
+			strategy: FixedPoll(time.Second),
+			expected: "http://example/v1/statement/abcd/1",
+		},
+		{
+			uri:      "http://example/v1/statement/abcd/1",
+			strategy: LongPoll(2 * time.Second),
+			expected: "http://example/v1/statement/abcd/1?maxWait=2000ms",
+		},
+		{
+			uri:      "http://example/v1/statement/abcd/1?slug=x",
+			strategy: LongPoll(time.Second),
+			expected: "http://example/v1/statement/abcd/1?maxWait=1000ms&slug=x",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := pageURL(tc.uri, tc.strategy); got != tc.expected {
+			t.Errorf("%s: got %s, wanted %s", tc.uri, got, tc.expected)
+		}
+	}
+}
+
+// queuedResponse reports the query as queued and then running before returning data.
+var queuedResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/query/abcd/1":
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/2", "stats": {"state": "QUEUED"}}`, r.Host)
+	case "/v1/query/abcd/2":
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/3", "stats": {"state": "RUNNING"}}`, r.Host)
+	case "/v1/query/abcd/3":
+		r.URL.Path = "/v1/query/abcd/1"
+		oneRowColResponse(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestRowsFetchPollStrategy(t *testing.T) {
+	ts := httptest.NewServer(queuedResponse)
+	defer ts.Close()
+
+	var waits []int
+	r := &rows{
+		conn: &conn{
+			client: http.DefaultClient,
+			pollStrategy: PollStrategyFunc(func(n int) time.Duration {
+				waits = append(waits, n)
+				return 0
+			}),
+		},
+		nextURI: ts.URL + "/v1/query/abcd/1",
+	}
+
+	values := make([]driver.Value, 1)
+	if err := r.Next(values); err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != "c0r0" {
+		t.Errorf("got %v, wanted %v", values[0], "c0r0")
+	}
+	if expected := []int{1, 2}; !reflect.DeepEqual(waits, expected) {
+		t.Errorf("got waits %v, wanted %v", waits, expected)
+	}
+}
@@ -0,0 +1,92 @@
+package prestgo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// cancelTimeout limits how long closing rows waits for the Presto server to
+// acknowledge the cancellation of an unfinished query.
+const cancelTimeout = 5 * time.Second
+
+// page is a page of results, or the error that prevented it being fetched, passed
+// from the prefetching goroutine to the reader of the rows.
+type page struct {
+	qresp *queryResponse
+	err   error
+}
+
+// receivePage returns the next page fetched in the background, starting the
+// prefetching goroutine on first use. Once the query has failed its error is
+// returned again rather than reading the closed channel as the end of the results.
+func (r *rows) receivePage() (*queryResponse, error) {
+	if r.pageErr != nil {
+		return nil, r.pageErr
+	}
+	if r.pages == nil {
+		r.startPrefetch()
+	}
+	p, ok := <-r.pages
+	if !ok {
+		return nil, io.EOF
+	}
+	r.pageErr = p.err
+	return p.qresp, p.err
+}
+
+// startPrefetch starts a goroutine that fetches pages until the query is finished,
+// fails or the rows are closed. The channel's capacity bounds the number of pages
+// fetched ahead of the reader.
+func (r *rows) startPrefetch() {
+	r.ctx, r.cancel = context.WithCancel(r.context())
+	r.pages = make(chan page, r.conn.prefetch)
+	go r.prefetch(r.pages)
+}
+
+func (r *rows) prefetch(pages chan<- page) {
+	defer close(pages)
+	for {
+		qresp, err := r.nextPage()
+		pages <- page{qresp: qresp, err: err}
+		if err != nil || r.nextURI == "" {
+			return
+		}
+	}
+}
+
+// stopPrefetch cancels any page being fetched in the background and waits for the
+// prefetching goroutine to exit. Pages still buffered are discarded, which also
+// unblocks the goroutine if it is waiting to send.
+func (r *rows) stopPrefetch() {
+	if r.pages == nil {
+		return
+	}
+	r.cancel()
+	for range r.pages {
+	}
+}
+
+// cancelQuery asks the Presto server to stop executing the query. Failures are
+// ignored since the server will eventually abandon a query that is not polled.
+func (r *rows) cancelQuery() {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("DELETE", r.nextURI, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add(r.conn.header("User"), r.conn.user)
+
+	r.conn.log(ctx, levelInfo, "canceling query", "query_id", r.id)
+	r.conn.count(MetricQueriesCanceled, 1)
+	resp, err := r.conn.do(req)
+	if err != nil {
+		r.conn.log(ctx, levelWarn, "failed to cancel query", "query_id", r.id, "error", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,197 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pagedServer serves a query whose results are split over a number of pages, each
+// holding a single row containing the page number.
+type pagedServer struct {
+	pages int
+
+	mu       sync.Mutex
+	fetched  int
+	canceled bool
+}
+
+func (s *pagedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/statement" {
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/v1/query/abcd/"))
+	if err != nil || n < 1 || n > s.pages {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	if r.Method == "DELETE" {
+		s.canceled = true
+	} else {
+		s.fetched++
+	}
+	s.mu.Unlock()
+	if r.Method == "DELETE" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	next, state := "", QueryStateFinished
+	if n < s.pages {
+		next, state = fmt.Sprintf(`"nextUri": "http://%s/v1/query/abcd/%d",`, r.Host, n+1), QueryStateRunning
+	}
+	fmt.Fprintf(w, `{"id": "abcd", %s "columns": [{"name": "page", "type": "bigint"}], "data": [[%d]], "stats": {"state": "%s"}}`, next, n, state)
+}
+
+func (s *pagedServer) status() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetched, s.canceled
+}
+
+func TestPrefetch(t *testing.T) {
+	for _, prefetch := range []int{0, 1, 3} {
+		s := &pagedServer{pages: 10}
+		r, done := testQuery(t, context.Background(), s, Config{PrefetchPages: prefetch}, "SELECT 1")
+
+		dest := make([]driver.Value, 1)
+		for i := 1; i <= s.pages; i++ {
+			if err := r.Next(dest); err != nil {
+				t.Fatalf("prefetch %d: page %d: got error %v", prefetch, i, err)
+			}
+			if dest[0] != int64(i) {
+				t.Errorf("prefetch %d: got %v, wanted %v", prefetch, dest[0], i)
+			}
+		}
+		if err := r.Next(dest); err != io.EOF {
+			t.Errorf("prefetch %d: got %v, wanted %v", prefetch, err, io.EOF)
+		}
+		r.Close()
+
+		if fetched, canceled := s.status(); fetched != s.pages || canceled {
+			t.Errorf("prefetch %d: got %d pages fetched and canceled %v, wanted %d and false", prefetch, fetched, canceled, s.pages)
+		}
+		done()
+	}
+}
+
+func TestPrefetchBackpressure(t *testing.T) {
+	s := &pagedServer{pages: 10}
+	r, done := testQuery(t, context.Background(), s, Config{PrefetchPages: 2}, "SELECT 1")
+	defer done()
+
+	if err := r.Next(make([]driver.Value, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The page being read, two buffered pages and one waiting to be sent
+	const limit = 4
+	deadline := time.Now().Add(time.Second)
+	for {
+		fetched, _ := s.status()
+		if fetched == limit {
+			break
+		}
+		if fetched > limit || time.Now().After(deadline) {
+			t.Fatalf("got %d pages fetched, wanted %d", fetched, limit)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if fetched, _ := s.status(); fetched != limit {
+		t.Errorf("got %d pages fetched, wanted fetching to pause at %d", fetched, limit)
+	}
+
+	// Close must stop the blocked goroutine and cancel the unfinished query
+	r.Close()
+	if _, canceled := s.status(); !canceled {
+		t.Errorf("query was not canceled on close")
+	}
+	if fetched, _ := s.status(); fetched != limit {
+		t.Errorf("got %d pages fetched after close, wanted %d", fetched, limit)
+	}
+}
+
+func TestPrefetchContextCanceled(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/statement" {
+			fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+			return
+		}
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	connector, err := NewConnector(Config{
+		DSN:           "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		PrefetchPages: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r, err := cn.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.Next(make([]driver.Value, 1)); err == nil || err == io.EOF {
+		t.Errorf("got %v, wanted context error", err)
+	}
+}
+
+func TestPrefetchQueryError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/statement" {
+			fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+			return
+		}
+		fmt.Fprint(w, `{"id": "abcd", "error": {"message": "line 1:1: mismatched input", "errorName": "SYNTAX_ERROR"}, "stats": {"state": "FAILED"}}`)
+	}))
+	defer ts.Close()
+
+	connector, err := NewConnector(Config{
+		DSN:           "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		PrefetchPages: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	rows, err := db.Query("SELEC 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	var qerr *QueryError
+	if !errors.As(rows.Err(), &qerr) || qerr.ErrorName != "SYNTAX_ERROR" {
+		t.Errorf("got %v, wanted the query's syntax error", rows.Err())
+	}
+}
@@ -0,0 +1,425 @@
+// Package prestotest provides a fake Presto coordinator for testing code that runs
+// queries using the prestgo driver, or any other client of the Presto protocol.
+//
+// The results of queries are registered with the server in advance, keyed by the text
+// of the query. The server reports each query as queued and then running for the
+// times given in its Result before returning its rows, in pages of the given size, or
+// failing it:
+//
+//	s := prestotest.NewServer()
+//	defer s.Close()
+//	s.Handle("SELECT name, age FROM people", prestotest.Result{
+//		Columns: []prestotest.Column{{Name: "name", Type: "varchar"}, {Name: "age", Type: "bigint"}},
+//		Rows:    [][]interface{}{{"alice", 31}, {"bob", 42}},
+//	})
+//	db, err := sql.Open("prestgo", s.DSN())
+package prestotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultVersion is the server version reported by a new Server, which selects the
+// Presto protocol in clients that detect it.
+const DefaultVersion = "0.280"
+
+// Column describes a column of a query's result.
+type Column struct {
+	Name string
+
+	// Type is the Presto type of the column, such as bigint or array(varchar).
+	Type string
+}
+
+// Error is the error a failed query reports.
+type Error struct {
+	Message   string
+	ErrorCode int
+	ErrorName string
+	ErrorType string
+}
+
+// Warning is a warning reported by a query.
+type Warning struct {
+	Code    int
+	Name    string
+	Message string
+}
+
+// Result describes how the server responds to a query.
+type Result struct {
+	Columns []Column
+
+	// Rows hold the values of the result, in column order. Values are encoded as
+	// Presto encodes values of their column's type: time.Time values are formatted as
+	// dates, times or timestamps, []byte values are base64 encoded and other values
+	// are encoded as JSON.
+	Rows [][]interface{}
+
+	// PageSize is the maximum number of rows in each page of results. If zero, all
+	// rows are returned in a single page.
+	PageSize int
+
+	// QueuedFor and RunningFor are the times the query remains queued and then running
+	// before the first page of results is returned or the query fails.
+	QueuedFor  time.Duration
+	RunningFor time.Duration
+
+	// Error, if set, fails the query.
+	Error *Error
+
+	// Warnings are reported with the last page of results.
+	Warnings []Warning
+
+	// UpdateType and UpdateCount are reported by statements that modify data, such
+	// as INSERT, or the session, such as SET SESSION.
+	UpdateType  string
+	UpdateCount *int64
+
+	// The following change the client's session when the query completes, as USE,
+	// SET SESSION, RESET SESSION, PREPARE and DEALLOCATE PREPARE statements do.
+	SetCatalog         string
+	SetSchema          string
+	SetSession         map[string]string
+	ClearSession       []string
+	AddedPrepare       map[string]string
+	DeallocatedPrepare []string
+}
+
+// Query records a query received by the server.
+type Query struct {
+	ID   string
+	Text string
+
+	// Header holds the headers the query was sent with, such as X-Presto-Session.
+	Header http.Header
+
+	// Canceled is true if the client canceled the query.
+	Canceled bool
+}
+
+// Server is a fake Presto coordinator listening on a local address. It may be used
+// concurrently.
+type Server struct {
+	// Version is reported by the server's /v1/info endpoint. It should be changed
+	// before the server is used.
+	Version string
+
+	ts *httptest.Server
+
+	mu      sync.Mutex
+	results map[string]Result
+	queries []*query
+	byID    map[string]*query
+}
+
+type query struct {
+	Query
+	result  Result
+	started time.Time
+	sent    int    // the number of rows sent
+	pages   []page // the pages served, indexed by their token less one
+}
+
+// page is a response to a request for a page of a query's results, kept so that a
+// repeated request for the same page receives the same response.
+type page struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (p page) write(w http.ResponseWriter) {
+	for k, v := range p.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(p.status)
+	w.Write(p.body)
+}
+
+// NewServer starts a Server. It should be stopped using Close.
+func NewServer() *Server {
+	s := &Server{
+		Version: DefaultVersion,
+		results: make(map[string]Result),
+		byID:    make(map[string]*query),
+	}
+	s.ts = httptest.NewServer(s)
+	return s
+}
+
+// Addr returns the host and port the server is listening on.
+func (s *Server) Addr() string {
+	return strings.TrimPrefix(s.ts.URL, "http://")
+}
+
+// DSN returns a data source name for the server that may be passed to sql.Open.
+func (s *Server) DSN() string {
+	return "presto://" + s.Addr()
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.ts.Close()
+}
+
+// Handle registers the result of query, replacing any result already registered for
+// it. Queries are matched by their text, ignoring surrounding space. Queries with no
+// registered result fail.
+func (s *Server) Handle(query string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[strings.TrimSpace(query)] = result
+}
+
+// Queries returns the queries received by the server, in the order they were received.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries := make([]Query, len(s.queries))
+	for i, q := range s.queries {
+		queries[i] = q.Query
+	}
+	return queries
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/info":
+		s.serveInfo(w)
+	case r.URL.Path == "/v1/statement" && r.Method == "POST":
+		s.serveStatement(w, r)
+	case strings.HasPrefix(r.URL.Path, "/v1/statement/executing/"):
+		s.servePage(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveInfo(w http.ResponseWriter) {
+	s.mu.Lock()
+	version := s.Version
+	s.mu.Unlock()
+	writeJSON(w, map[string]interface{}{
+		"nodeVersion": map[string]string{"version": version},
+		"environment": "test",
+		"coordinator": true,
+		"starting":    false,
+		"uptime":      "1.00m",
+	})
+}
+
+func (s *Server) serveStatement(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text := string(body)
+
+	s.mu.Lock()
+	result, ok := s.results[strings.TrimSpace(text)]
+	if !ok {
+		result = Result{Error: &Error{
+			Message:   fmt.Sprintf("prestotest: no result registered for query %q", text),
+			ErrorCode: 1,
+			ErrorName: "GENERIC_USER_ERROR",
+			ErrorType: "USER_ERROR",
+		}}
+	}
+	q := &query{
+		Query: Query{
+			ID:     fmt.Sprintf("20060102_150405_%05d_test", len(s.queries)+1),
+			Text:   text,
+			Header: r.Header.Clone(),
+		},
+		result:  result,
+		started: time.Now(),
+	}
+	s.queries = append(s.queries, q)
+	s.byID[q.ID] = q
+	s.mu.Unlock()
+
+	writeJSON(w, response{
+		ID:      q.ID,
+		InfoURI: fmt.Sprintf("http://%s/ui/query.html?%s", r.Host, q.ID),
+		NextURI: pageURI(r, q.ID, 1),
+		Stats:   stats{State: "QUEUED", Queued: true},
+	})
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/statement/executing/"), "/")
+	token, err := strconv.Atoi(parts[len(parts)-1])
+	if len(parts) != 2 || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.byID[parts[0]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == "DELETE" {
+		q.Canceled = true
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if q.Canceled {
+		http.Error(w, "query canceled", http.StatusGone)
+		return
+	}
+
+	// Pages are requested in turn, but a client may repeat a request whose response
+	// it didn't receive
+	switch {
+	case token >= 1 && token <= len(q.pages):
+		q.pages[token-1].write(w)
+		return
+	case token != len(q.pages)+1:
+		http.NotFound(w, r)
+		return
+	}
+	p := nextPage(r, q, token)
+	q.pages = append(q.pages, p)
+	p.write(w)
+}
+
+// nextPage returns the response to the request for the page with the given token,
+// which follows the last page served.
+func nextPage(r *http.Request, q *query, token int) page {
+	elapsed := time.Since(q.started)
+	resp := response{
+		ID:      q.ID,
+		InfoURI: fmt.Sprintf("http://%s/ui/query.html?%s", r.Host, q.ID),
+		NextURI: pageURI(r, q.ID, token+1),
+		Stats: stats{
+			ElapsedTimeMillis: elapsed.Milliseconds(),
+			ProcessedRows:     q.sent,
+		},
+	}
+	header := make(http.Header)
+	res := q.result
+	switch {
+	case elapsed < res.QueuedFor:
+		resp.Stats.State, resp.Stats.Queued = "QUEUED", true
+		resp.Stats.QueuedTimeMillis = elapsed.Milliseconds()
+		return newPage(header, resp)
+	case elapsed < res.QueuedFor+res.RunningFor:
+		resp.Stats.State, resp.Stats.Scheduled = "RUNNING", true
+		resp.Stats.QueuedTimeMillis = res.QueuedFor.Milliseconds()
+		return newPage(header, resp)
+	}
+
+	resp.Stats.QueuedTimeMillis = res.QueuedFor.Milliseconds()
+	resp.Stats.Scheduled = true
+	if res.Error != nil {
+		resp.NextURI = ""
+		resp.Stats.State = "FAILED"
+		resp.Error = &queryError{
+			Message:   res.Error.Message,
+			ErrorCode: res.Error.ErrorCode,
+			ErrorName: res.Error.ErrorName,
+			ErrorType: res.Error.ErrorType,
+		}
+		resp.Warnings = warnings(res.Warnings)
+		return newPage(header, resp)
+	}
+
+	resp.Columns = columns(res.Columns)
+	end := len(res.Rows)
+	if res.PageSize > 0 && q.sent+res.PageSize < end {
+		end = q.sent + res.PageSize
+	}
+	resp.Data = make([][]interface{}, 0, end-q.sent)
+	for _, row := range res.Rows[q.sent:end] {
+		resp.Data = append(resp.Data, encodeRow(res.Columns, row))
+	}
+	q.sent = end
+	resp.Stats.ProcessedRows = q.sent
+
+	if q.sent < len(res.Rows) {
+		resp.Stats.State = "RUNNING"
+	} else {
+		resp.NextURI = ""
+		resp.Stats.State = "FINISHED"
+		resp.Warnings = warnings(res.Warnings)
+		resp.UpdateType = res.UpdateType
+		resp.UpdateCount = res.UpdateCount
+		setSessionHeaders(header, headerPrefix(r), res)
+	}
+	return newPage(header, resp)
+}
+
+// newPage encodes resp as a page to be sent with header.
+func newPage(header http.Header, resp response) page {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		return page{status: http.StatusInternalServerError, header: header, body: []byte(err.Error() + "\n")}
+	}
+	header.Set("Content-Type", "application/json")
+	return page{status: http.StatusOK, header: header, body: append(body, '\n')}
+}
+
+// headerPrefix returns the prefix of the protocol headers used by the client.
+func headerPrefix(r *http.Request) string {
+	if r.Header.Get("X-Trino-User") != "" {
+		return "X-Trino-"
+	}
+	return "X-Presto-"
+}
+
+func setSessionHeaders(h http.Header, prefix string, res Result) {
+	if res.SetCatalog != "" {
+		h.Set(prefix+"Set-Catalog", res.SetCatalog)
+	}
+	if res.SetSchema != "" {
+		h.Set(prefix+"Set-Schema", res.SetSchema)
+	}
+	for _, k := range sortedKeys(res.SetSession) {
+		h.Add(prefix+"Set-Session", k+"="+url.QueryEscape(res.SetSession[k]))
+	}
+	for _, k := range res.ClearSession {
+		h.Add(prefix+"Clear-Session", k)
+	}
+	for _, k := range sortedKeys(res.AddedPrepare) {
+		h.Add(prefix+"Added-Prepare", k+"="+url.QueryEscape(res.AddedPrepare[k]))
+	}
+	for _, k := range res.DeallocatedPrepare {
+		h.Add(prefix+"Deallocated-Prepare", k)
+	}
+}
+
+func pageURI(r *http.Request, id string, token int) string {
+	return fmt.Sprintf("http://%s/v1/statement/executing/%s/%d", r.Host, id, token)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,343 @@
+package prestotest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avct/prestgo"
+	"github.com/avct/prestgo/prestotest"
+)
+
+func openDB(t *testing.T, s *prestotest.Server, conf prestgo.Config) *sql.DB {
+	conf.DSN = s.DSN()
+	conf.PollStrategy = prestgo.FixedPoll(time.Millisecond)
+	connector, err := prestgo.NewConnector(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sql.OpenDB(connector)
+}
+
+func TestTypedRowsInPages(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	ts := time.Date(2015, 2, 9, 18, 26, 2, 13000000, time.Local)
+	s.Handle("SELECT * FROM t", prestotest.Result{
+		Columns: []prestotest.Column{
+			{Name: "name", Type: "varchar"},
+			{Name: "n", Type: "bigint"},
+			{Name: "ok", Type: "boolean"},
+			{Name: "at", Type: "timestamp"},
+			{Name: "tags", Type: "array(varchar)"},
+		},
+		Rows: [][]interface{}{
+			{"a", 1, true, ts, []string{"x"}},
+			{"b", 2, false, ts, []string{}},
+			{"c", nil, nil, nil, nil},
+		},
+		PageSize:   2,
+		QueuedFor:  10 * time.Millisecond,
+		RunningFor: 10 * time.Millisecond,
+	})
+
+	metrics := &prestgo.MemoryMetrics{}
+	db := openDB(t, s, prestgo.Config{Metrics: metrics})
+	defer db.Close()
+
+	rows, err := db.Query("SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var name string
+		var n sql.NullInt64
+		var ok sql.NullBool
+		var at interface{}
+		var tags interface{}
+		if err := rows.Scan(&name, &n, &ok, &at, &tags); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %v %v %v %v", name, n.Int64, ok.Bool, at, tags))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		fmt.Sprintf("a 1 true %v [x]", ts),
+		fmt.Sprintf("b 2 false %v []", ts),
+		"c 0 false <nil> <nil>",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got rows %q, wanted %q", got, expected)
+	}
+
+	labels := prestgo.MetricLabels{Catalog: prestgo.DefaultCatalog}
+	if pages := metrics.Counter(prestgo.MetricPagesFetched, labels); pages < 2 {
+		t.Errorf("got %d pages, wanted at least 2", pages)
+	}
+	if queued := metrics.Observations(prestgo.MetricQueueTime, labels); len(queued) != 1 || queued[0] < 0.01 {
+		t.Errorf("got queue time %v, wanted at least 10ms", queued)
+	}
+}
+
+// losingTransport loses the response to the first request for the second page of a
+// query's results, after the server has sent it.
+type losingTransport struct {
+	lost bool
+}
+
+func (lt *losingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil && !lt.lost && strings.HasSuffix(req.URL.Path, "/2") {
+		lt.lost = true
+		resp.Body.Close()
+		return nil, errors.New("connection reset")
+	}
+	return resp, err
+}
+
+func TestRepeatedPageRequest(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	s.Handle("SELECT n FROM t", prestotest.Result{
+		Columns:  []prestotest.Column{{Name: "n", Type: "bigint"}},
+		Rows:     [][]interface{}{{1}, {2}, {3}, {4}},
+		PageSize: 1,
+	})
+
+	transport := &losingTransport{}
+	db := openDB(t, s, prestgo.Config{
+		Client:      &http.Client{Transport: transport},
+		RetryPolicy: &prestgo.RetryPolicy{MaxAttempts: 2},
+	})
+	defer db.Close()
+
+	rows, err := db.Query("SELECT n FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []int64
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !transport.lost {
+		t.Fatal("no response was lost")
+	}
+	if expected := []int64{1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got rows %v, wanted %v", got, expected)
+	}
+}
+
+func TestFailedQuery(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	s.Handle("SELECT * FROM missing", prestotest.Result{
+		Error: &prestotest.Error{Message: "Table missing does not exist", ErrorName: "TABLE_NOT_FOUND", ErrorType: "USER_ERROR"},
+	})
+
+	db := openDB(t, s, prestgo.Config{})
+	defer db.Close()
+
+	if qerr := queryError(db, "SELECT * FROM missing"); qerr == nil || qerr.ErrorName != "TABLE_NOT_FOUND" || qerr.Message != "Table missing does not exist" {
+		t.Errorf("got %v, wanted the registered error", qerr)
+	}
+	if qerr := queryError(db, "SELECT 1"); qerr == nil {
+		t.Errorf("got no error for an unregistered query, wanted one")
+	}
+}
+
+func queryError(db *sql.DB, query string) *prestgo.QueryError {
+	rows, err := db.Query(query)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	var qerr *prestgo.QueryError
+	errors.As(err, &qerr)
+	return qerr
+}
+
+func TestWarnings(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	s.Handle("SELECT 1", prestotest.Result{
+		Columns:  []prestotest.Column{{Name: "_col0", Type: "integer"}},
+		Rows:     [][]interface{}{{1}},
+		Warnings: []prestotest.Warning{{Code: 1, Name: "PARSER_WARNING", Message: "careful"}},
+	})
+
+	var warnings []string
+	db := openDB(t, s, prestgo.Config{OnWarning: func(id string, w prestgo.Warning) {
+		warnings = append(warnings, w.Code.Name+": "+w.Message)
+	}})
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"PARSER_WARNING: careful"}; !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("got warnings %q, wanted %q", warnings, expected)
+	}
+}
+
+func TestSessionChanges(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	s.Handle("USE hive.web", prestotest.Result{SetCatalog: "hive", SetSchema: "web", UpdateType: "USE"})
+	s.Handle("SET SESSION join_distribution_type = 'BROADCAST'", prestotest.Result{
+		SetSession: map[string]string{"join_distribution_type": "BROADCAST"},
+		UpdateType: "SET SESSION",
+	})
+	s.Handle("SELECT 1", prestotest.Result{
+		Columns: []prestotest.Column{{Name: "_col0", Type: "integer"}},
+		Rows:    [][]interface{}{{1}},
+	})
+
+	db := openDB(t, s, prestgo.Config{})
+	defer db.Close()
+	c, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	script := "USE hive.web; SET SESSION join_distribution_type = 'BROADCAST'; SELECT 1"
+	if err := prestgo.ExecScript(context.Background(), c, script); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := s.Queries()
+	if len(queries) != 3 {
+		t.Fatalf("got %d queries, wanted 3", len(queries))
+	}
+	last := queries[2].Header
+	if got := last.Get("X-Presto-Catalog") + "." + last.Get("X-Presto-Schema"); got != "hive.web" {
+		t.Errorf("got catalog and schema %q, wanted %q", got, "hive.web")
+	}
+	if got := last.Get("X-Presto-Session"); got != "join_distribution_type=BROADCAST" {
+		t.Errorf("got session %q, wanted %q", got, "join_distribution_type=BROADCAST")
+	}
+}
+
+func TestCanceledQuery(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	s.Handle("SELECT slow", prestotest.Result{
+		Columns:    []prestotest.Column{{Name: "_col0", Type: "integer"}},
+		Rows:       [][]interface{}{{1}},
+		RunningFor: time.Minute,
+	})
+
+	db := openDB(t, s, prestgo.Config{})
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, "SELECT slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if rows.Err() == nil {
+		t.Fatal("got no error, wanted the query to time out")
+	}
+	rows.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for !s.Queries()[0].Canceled {
+		if time.Now().After(deadline) {
+			t.Fatal("query was not canceled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTrinoProtocol(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+	s.Version = "435"
+
+	s.Handle("USE hive.web", prestotest.Result{SetCatalog: "hive", SetSchema: "web"})
+	s.Handle("SELECT 1", prestotest.Result{
+		Columns: []prestotest.Column{{Name: "_col0", Type: "integer"}},
+		Rows:    [][]interface{}{{1}},
+	})
+
+	db := openDB(t, s, prestgo.Config{Protocol: prestgo.ProtocolAuto})
+	defer db.Close()
+	c, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := prestgo.ExecScript(context.Background(), c, "USE hive.web; SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Queries()[1].Header.Get("X-Trino-Catalog"); got != "hive" {
+		t.Errorf("got catalog %q, wanted %q", got, "hive")
+	}
+}
+
+func Example() {
+	s := prestotest.NewServer()
+	defer s.Close()
+
+	s.Handle("SELECT name, age FROM people", prestotest.Result{
+		Columns: []prestotest.Column{{Name: "name", Type: "varchar"}, {Name: "age", Type: "bigint"}},
+		Rows:    [][]interface{}{{"alice", 31}, {"bob", 42}},
+	})
+
+	db, err := sql.Open("prestgo", s.DSN())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT name, age FROM people")
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var age int64
+		if err := rows.Scan(&name, &age); err != nil {
+			panic(err)
+		}
+		fmt.Println(name, age)
+	}
+	// Output:
+	// alice 31
+	// bob 42
+}
@@ -0,0 +1,132 @@
+package prestotest
+
+import (
+	"strings"
+	"time"
+)
+
+// response is a page of a query's results, as sent by a Presto coordinator.
+type response struct {
+	ID          string          `json:"id"`
+	InfoURI     string          `json:"infoUri"`
+	NextURI     string          `json:"nextUri,omitempty"`
+	Columns     []column        `json:"columns,omitempty"`
+	Data        [][]interface{} `json:"data,omitempty"`
+	Stats       stats           `json:"stats"`
+	Error       *queryError     `json:"error,omitempty"`
+	Warnings    []warning       `json:"warnings,omitempty"`
+	UpdateType  string          `json:"updateType,omitempty"`
+	UpdateCount *int64          `json:"updateCount,omitempty"`
+}
+
+type column struct {
+	Name          string        `json:"name"`
+	Type          string        `json:"type"`
+	TypeSignature typeSignature `json:"typeSignature"`
+}
+
+type typeSignature struct {
+	RawType          string        `json:"rawType"`
+	TypeArguments    []interface{} `json:"typeArguments"`
+	LiteralArguments []interface{} `json:"literalArguments"`
+}
+
+type stats struct {
+	State             string `json:"state"`
+	Queued            bool   `json:"queued"`
+	Scheduled         bool   `json:"scheduled"`
+	ProcessedRows     int    `json:"processedRows"`
+	ElapsedTimeMillis int64  `json:"elapsedTimeMillis"`
+	QueuedTimeMillis  int64  `json:"queuedTimeMillis"`
+}
+
+type queryError struct {
+	Message   string `json:"message"`
+	ErrorCode int    `json:"errorCode"`
+	ErrorName string `json:"errorName"`
+	ErrorType string `json:"errorType"`
+}
+
+type warning struct {
+	WarningCode struct {
+		Code int    `json:"code"`
+		Name string `json:"name"`
+	} `json:"warningCode"`
+	Message string `json:"message"`
+}
+
+func columns(cols []Column) []column {
+	out := make([]column, len(cols))
+	for i, c := range cols {
+		raw := c.Type
+		if n := strings.IndexByte(raw, '('); n >= 0 {
+			raw = raw[:n]
+		}
+		out[i] = column{
+			Name: c.Name,
+			Type: c.Type,
+			TypeSignature: typeSignature{
+				RawType:          raw,
+				TypeArguments:    []interface{}{},
+				LiteralArguments: []interface{}{},
+			},
+		}
+	}
+	return out
+}
+
+func warnings(ws []Warning) []warning {
+	var out []warning
+	for _, w := range ws {
+		var pw warning
+		pw.WarningCode.Code = w.Code
+		pw.WarningCode.Name = w.Name
+		pw.Message = w.Message
+		out = append(out, pw)
+	}
+	return out
+}
+
+// encodeRow converts the values of a row to the form Presto uses for their column's
+// type.
+func encodeRow(cols []Column, row []interface{}) []interface{} {
+	out := make([]interface{}, len(row))
+	for i, v := range row {
+		typ := ""
+		if i < len(cols) {
+			typ = cols[i].Type
+		}
+		out[i] = encodeValue(typ, v)
+	}
+	return out
+}
+
+func encodeValue(typ string, v interface{}) interface{} {
+	t, ok := v.(time.Time)
+	if !ok {
+		return v
+	}
+	withZone := strings.HasSuffix(typ, "with time zone")
+	switch {
+	case typ == "date":
+		return t.Format("2006-01-02")
+	case strings.HasPrefix(typ, "time") && !strings.HasPrefix(typ, "timestamp"):
+		if withZone {
+			return t.Format("15:04:05.000-07:00")
+		}
+		return t.Format("15:04:05.000")
+	case withZone:
+		return t.Format("2006-01-02 15:04:05.000 ") + zoneName(t.Location())
+	default:
+		return t.Format("2006-01-02 15:04:05.000")
+	}
+}
+
+// zoneName returns the name of loc as Presto reports it: its IANA name or, for zones
+// without one, its UTC offset.
+func zoneName(loc *time.Location) string {
+	if _, err := time.LoadLocation(loc.String()); err == nil && loc.String() != "" {
+		return loc.String()
+	}
+	return time.Now().In(loc).Format("-07:00")
+}
@@ -0,0 +1,382 @@
+package prestotest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the values of redacted headers in fixtures.
+const Redacted = "REDACTED"
+
+// DefaultRedactHeaders lists the headers whose values are always redacted by a
+// Recorder, since they hold credentials.
+var DefaultRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Presto-Extra-Credential",
+	"X-Trino-Extra-Credential",
+}
+
+// Fixture holds the HTTP exchanges of a recorded session with a coordinator.
+type Fixture struct {
+	// Statements holds the exchanges of each query, in the order the queries were
+	// sent.
+	Statements []Statement `json:"statements"`
+
+	// Requests holds the exchanges that were not part of a query, such as requests for
+	// /v1/info.
+	Requests []Exchange `json:"requests,omitempty"`
+}
+
+// Statement holds the exchanges of a query: the POST that sent it followed by the
+// request for each page of its results, in order.
+type Statement struct {
+	SQL       string     `json:"sql"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is a recorded request and its response. Compressed responses are recorded
+// decompressed.
+type Exchange struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	RequestHeader http.Header `json:"requestHeader,omitempty"`
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header,omitempty"`
+	Body          string      `json:"body"`
+}
+
+// LoadFixture reads a fixture written by Recorder.Save.
+func LoadFixture(path string) (*Fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("prestotest: invalid fixture %s: %v", path, err)
+	}
+	return &f, nil
+}
+
+// NormalizeSQL returns the form of query used to match recorded statements: with runs
+// of space collapsed and trailing semicolons removed.
+func NormalizeSQL(query string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(query), " "), "; ")
+}
+
+// Recorder is an http.RoundTripper that records the exchanges of the queries sent
+// through it, so that they can be saved as a fixture and replayed by a Replayer. The
+// values of credential headers are redacted, as are the tokens issued by the token
+// server during OAuth2 external authentication. It may be used concurrently.
+type Recorder struct {
+	// Transport sends the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// RedactHeaders lists headers whose values are redacted in addition to
+	// DefaultRedactHeaders.
+	RedactHeaders []string
+
+	mu      sync.Mutex
+	fixture Fixture
+	next    map[string]int // statement index keyed by the page URIs they returned
+}
+
+// RoundTrip sends req using the Recorder's transport and records the exchange.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	decoded, err := decodeBody(resp.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		return nil, err
+	}
+	header := r.redact(resp.Header)
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	ex := Exchange{
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: r.redact(req.Header),
+		Status:        resp.StatusCode,
+		Header:        header,
+		Body:          string(decoded),
+	}
+	r.record(ex, reqBody)
+	return resp, nil
+}
+
+// record adds an exchange to the statement it belongs to.
+func (r *Recorder) record(ex Exchange, reqBody []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == nil {
+		r.next = make(map[string]int)
+	}
+
+	i, ok := r.next[pageKey(ex.URL)]
+	switch {
+	case ex.Method == "POST" && strings.HasSuffix(pageKey(ex.URL), "/v1/statement"):
+		r.fixture.Statements = append(r.fixture.Statements, Statement{SQL: string(reqBody)})
+		i = len(r.fixture.Statements) - 1
+	case !ok:
+		ex.Body = redactToken(ex.Body)
+		r.fixture.Requests = append(r.fixture.Requests, ex)
+		return
+	}
+	r.fixture.Statements[i].Exchanges = append(r.fixture.Statements[i].Exchanges, ex)
+	if uri := nextURI(ex.Body); uri != "" {
+		r.next[pageKey(uri)] = i
+	}
+}
+
+func (r *Recorder) redact(h http.Header) http.Header {
+	h = h.Clone()
+	for _, names := range [][]string{DefaultRedactHeaders, r.RedactHeaders} {
+		for _, name := range names {
+			for j := range h[http.CanonicalHeaderKey(name)] {
+				h[http.CanonicalHeaderKey(name)][j] = Redacted
+			}
+		}
+	}
+	return h
+}
+
+// redactToken redacts the token from a response of an OAuth2 token server, which is a
+// JSON object holding either the token or the uri to poll for it. Other bodies are
+// returned unchanged.
+func redactToken(body string) string {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(body), &fields) != nil {
+		return body
+	}
+	if _, ok := fields["token"]; !ok {
+		return body
+	}
+	fields["token"], _ = json.Marshal(Redacted)
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+// Fixture returns a copy of the exchanges recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, _ := json.Marshal(&r.fixture)
+	var f Fixture
+	json.Unmarshal(b, &f)
+	return &f
+}
+
+// Save writes the exchanges recorded so far to a fixture file at path.
+func (r *Recorder) Save(path string) error {
+	b, err := json.MarshalIndent(r.Fixture(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Replayer is an http.RoundTripper that serves the responses recorded in a Fixture.
+// A query is matched with the next recorded statement with the same normalized SQL,
+// and requests for its pages are served the recorded pages in order, regardless of
+// the address of the coordinator. Requests that were not recorded fail. It may be used
+// concurrently.
+type Replayer struct {
+	mu       sync.Mutex
+	fixture  *Fixture
+	used     map[int]bool
+	requests map[string]int // request counts keyed by method and URL
+	next     map[string]replayPage
+}
+
+type replayPage struct {
+	statement int
+	exchange  int
+}
+
+// NewReplayer returns a Replayer serving the responses recorded in f.
+func NewReplayer(f *Fixture) *Replayer {
+	return &Replayer{
+		fixture:  f,
+		used:     make(map[int]bool),
+		requests: make(map[string]int),
+		next:     make(map[string]replayPage),
+	}
+}
+
+// RoundTrip serves the recorded response to req.
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := pageKey(req.URL.String())
+	if req.Method == "POST" && strings.HasSuffix(key, "/v1/statement") {
+		sql := NormalizeSQL(string(reqBody))
+		for i, stmt := range p.fixture.Statements {
+			if !p.used[i] && NormalizeSQL(stmt.SQL) == sql && len(stmt.Exchanges) > 0 {
+				p.used[i] = true
+				return p.serve(req, replayPage{statement: i})
+			}
+		}
+		return nil, fmt.Errorf("prestotest: no recorded statement matches %q", sql)
+	}
+
+	if page, ok := p.next[key]; ok {
+		delete(p.next, key)
+		exchanges := p.fixture.Statements[page.statement].Exchanges
+		if page.exchange < len(exchanges) && exchanges[page.exchange].Method == req.Method {
+			return p.serve(req, page)
+		}
+	}
+	if req.Method == "DELETE" && strings.HasPrefix(key, "/v1/statement/") {
+		// The query was canceled at a different point from the recording
+		return replayResponse(req, http.StatusNoContent, nil, ""), nil
+	}
+
+	// Requests that were not part of a query are served in the order they were
+	// recorded, repeating the last once they are used up
+	n := p.requests[req.Method+" "+key]
+	var found *Exchange
+	for i := range p.fixture.Requests {
+		ex := &p.fixture.Requests[i]
+		if ex.Method != req.Method || pageKey(ex.URL) != key {
+			continue
+		}
+		found = ex
+		if n == 0 {
+			break
+		}
+		n--
+	}
+	if found == nil {
+		return nil, fmt.Errorf("prestotest: no recorded response for %s %s", req.Method, req.URL)
+	}
+	p.requests[req.Method+" "+key]++
+	return replayResponse(req, found.Status, found.Header, found.Body), nil
+}
+
+// serve responds with a recorded exchange of a statement, expecting the request that
+// followed it in the recording next. This is usually a request for the page it
+// refers to, but may repeat the request if it was retried.
+func (p *Replayer) serve(req *http.Request, page replayPage) (*http.Response, error) {
+	exchanges := p.fixture.Statements[page.statement].Exchanges
+	ex := exchanges[page.exchange]
+	if next := page.exchange + 1; next < len(exchanges) {
+		p.next[pageKey(exchanges[next].URL)] = replayPage{statement: page.statement, exchange: next}
+	}
+	return replayResponse(req, ex.Status, ex.Header, ex.Body), nil
+}
+
+func replayResponse(req *http.Request, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// pageKey returns the part of a URI used to match requests: its path. The host is
+// ignored so that fixtures may be replayed against any address, and the query is
+// ignored since clients add parameters such as maxWait.
+func pageKey(uri string) string {
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+		if j := strings.IndexByte(uri, '/'); j >= 0 {
+			uri = uri[j:]
+		} else {
+			uri = "/"
+		}
+	}
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	return uri
+}
+
+// nextURI returns the nextUri of a page of results, if it has one.
+func nextURI(body string) string {
+	var page struct {
+		NextURI string `json:"nextUri"`
+	}
+	if json.Unmarshal([]byte(body), &page) != nil {
+		return ""
+	}
+	return page.NextURI
+}
+
+// decodeBody decompresses a response body sent with encoding.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	var rd io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		rd, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		rd, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("prestotest: unsupported content encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(rd)
+}
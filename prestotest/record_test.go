@@ -0,0 +1,235 @@
+package prestotest_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avct/prestgo"
+	"github.com/avct/prestgo/prestotest"
+)
+
+func queryValues(t *testing.T, cn driver.Conn, query string) ([]interface{}, error) {
+	r, err := cn.(driver.QueryerContext).QueryContext(context.Background(), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var values []interface{}
+	dest := make([]driver.Value, len(r.Columns()))
+	for {
+		if err := r.Next(dest); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, dest[0])
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prestotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixture.json")
+
+	// Record a session with the fake coordinator
+	s := prestotest.NewServer()
+	s.Handle("SELECT n FROM t", prestotest.Result{
+		Columns:   []prestotest.Column{{Name: "n", Type: "bigint"}},
+		Rows:      [][]interface{}{{1}, {2}, {3}},
+		PageSize:  2,
+		QueuedFor: 10 * time.Millisecond,
+	})
+	s.Handle("SELECT 'x'", prestotest.Result{
+		Columns: []prestotest.Column{{Name: "_col0", Type: "varchar"}},
+		Rows:    [][]interface{}{{"x"}},
+	})
+
+	rec := &prestotest.Recorder{RedactHeaders: []string{"X-Presto-Client-Info"}}
+	connector, err := prestgo.NewConnector(prestgo.Config{
+		DSN:              s.DSN(),
+		Client:           &http.Client{Transport: rec},
+		Protocol:         prestgo.ProtocolAuto,
+		ExtraCredentials: map[string]string{"token": "secret"},
+		ClientInfo:       "private",
+		PollStrategy:     prestgo.FixedPoll(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]interface{}{
+		"SELECT n FROM t": {int64(1), int64(2), int64(3)},
+		"SELECT 'x'":      {"x"},
+	}
+	for _, query := range []string{"SELECT n FROM t", "SELECT 'x'"} {
+		got, err := queryValues(t, cn, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected[query]) {
+			t.Fatalf("%s: recorded %v, wanted %v", query, got, expected[query])
+		}
+	}
+	s.Close()
+
+	if err := rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret") || strings.Contains(string(b), "private") {
+		t.Errorf("fixture holds redacted headers:\n%s", b)
+	}
+
+	// Replay it, sending queries to a different address
+	f, err := prestotest.LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Statements) != 2 || len(f.Statements[0].Exchanges) < 3 {
+		t.Fatalf("got %d statements recorded, wanted 2 with all their pages", len(f.Statements))
+	}
+	connector, err = prestgo.NewConnector(prestgo.Config{
+		DSN:          "presto://replay:8080",
+		Client:       &http.Client{Transport: prestotest.NewReplayer(f)},
+		Protocol:     prestgo.ProtocolAuto,
+		PollStrategy: prestgo.FixedPoll(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err = connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"SELECT 'x';", "SELECT  n\n FROM t"} {
+		got, err := queryValues(t, cn, query)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		want := expected[prestotest.NormalizeSQL(query)]
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: replayed %v, wanted %v", query, got, want)
+		}
+	}
+
+	if _, err := queryValues(t, cn, "SELECT 'x'"); err == nil {
+		t.Errorf("got no error replaying a statement a second time, wanted one")
+	}
+	if _, err := queryValues(t, cn, "SELECT 2"); err == nil {
+		t.Errorf("got no error replaying an unrecorded statement, wanted one")
+	}
+}
+
+// authProxy serves s using TLS, requiring OAuth2 external authentication for the
+// queries sent to it and issuing a token after a single round of polling.
+func authProxy(s *prestotest.Server) *httptest.Server {
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: s.Addr()})
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		// The uris of pages returned by s are reached through the proxy
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		b = bytes.Replace(b, []byte("http://"), []byte("https://"), -1)
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+		resp.ContentLength = int64(len(b))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(b)))
+		return nil
+	}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token/1":
+			fmt.Fprintf(w, `{"nextUri": "https://%s/oauth2/token/2"}`, r.Host)
+		case "/oauth2/token/2":
+			fmt.Fprint(w, `{"token": "t0k3n"}`)
+		case "/v1/statement":
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer x_token_server="https://%s/oauth2/token/1"`, r.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fallthrough
+		default:
+			proxy.ServeHTTP(w, r)
+		}
+	}))
+}
+
+func TestRecordExternalAuth(t *testing.T) {
+	s := prestotest.NewServer()
+	defer s.Close()
+	s.Handle("SELECT 'x'", prestotest.Result{
+		Columns: []prestotest.Column{{Name: "_col0", Type: "varchar"}},
+		Rows:    [][]interface{}{{"x"}},
+	})
+	ts := authProxy(s)
+	defer ts.Close()
+
+	open := func(transport http.RoundTripper) driver.Conn {
+		connector, err := prestgo.NewConnector(prestgo.Config{
+			DSN:          "presto://" + strings.TrimPrefix(ts.URL, "https://") + "?ssl=true",
+			Client:       &http.Client{Transport: transport},
+			ExternalAuth: func(string) error { return nil },
+			PollStrategy: prestgo.FixedPoll(time.Millisecond),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cn, err := connector.Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cn
+	}
+
+	rec := &prestotest.Recorder{Transport: ts.Client().Transport}
+	if _, err := queryValues(t, open(rec), "SELECT 'x'"); err != nil {
+		t.Fatal(err)
+	}
+	f := rec.Fixture()
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "t0k3n") {
+		t.Errorf("fixture holds the issued token:\n%s", b)
+	}
+	if len(f.Requests) != 2 {
+		t.Errorf("got %d token server requests recorded, wanted 2", len(f.Requests))
+	}
+
+	got, err := queryValues(t, open(prestotest.NewReplayer(f)), "SELECT 'x'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []interface{}{"x"}) {
+		t.Errorf("replayed %v, wanted [x]", got)
+	}
+}
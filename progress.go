@@ -0,0 +1,150 @@
+package prestgo
+
+import (
+	"context"
+	"time"
+)
+
+// QueryProgress is a snapshot of the progress of a query, as reported by the Presto
+// server each time the driver polls for results.
+type QueryProgress struct {
+	QueryID   string
+	State     string
+	Queued    bool
+	Scheduled bool
+	Nodes     int
+
+	TotalSplits     int
+	QueuedSplits    int
+	RunningSplits   int
+	CompletedSplits int
+
+	ProcessedRows   int64
+	ProcessedBytes  int64
+	PeakMemoryBytes int64
+	SpilledBytes    int64
+
+	CPUTime     time.Duration
+	WallTime    time.Duration
+	QueuedTime  time.Duration
+	ElapsedTime time.Duration
+
+	// RootStage is the root of the tree of stages executing the query. It is nil until
+	// the query has been planned.
+	RootStage *StageProgress
+
+	// progressPercentage is the progress reported by servers that calculate it.
+	progressPercentage *float64
+}
+
+// StageProgress is a snapshot of the progress of one stage of a query.
+type StageProgress struct {
+	StageID string
+	State   string
+	Done    bool
+	Nodes   int
+
+	TotalSplits     int
+	QueuedSplits    int
+	RunningSplits   int
+	CompletedSplits int
+
+	ProcessedRows  int64
+	ProcessedBytes int64
+
+	CPUTime  time.Duration
+	WallTime time.Duration
+
+	SubStages []StageProgress
+}
+
+// PercentComplete estimates how much of the query has been completed, between 0 and
+// 100. It uses the progress reported by the server if available and the proportion of
+// completed splits otherwise.
+func (p QueryProgress) PercentComplete() float64 {
+	if p.progressPercentage != nil {
+		return *p.progressPercentage
+	}
+	if p.State == QueryStateFinished {
+		return 100
+	}
+	if p.TotalSplits == 0 {
+		return 0
+	}
+	return 100 * float64(p.CompletedSplits) / float64(p.TotalSplits)
+}
+
+// ProgressHandler is called with a snapshot of a query's progress each time the
+// driver receives a response from the server.
+type ProgressHandler func(p QueryProgress)
+
+// WithProgress returns a copy of ctx that reports the progress of queries run with it
+// to h, in place of any handler configured for the connection.
+func WithProgress(ctx context.Context, h ProgressHandler) context.Context {
+	return context.WithValue(ctx, progressKey, h)
+}
+
+func newQueryProgress(id string, s *stmtStats) QueryProgress {
+	p := QueryProgress{
+		QueryID:            id,
+		State:              s.State,
+		Queued:             s.Queued,
+		Scheduled:          s.Scheduled,
+		Nodes:              s.Nodes,
+		TotalSplits:        s.TotalSplits,
+		QueuedSplits:       s.QueuesSplits,
+		RunningSplits:      s.RunningSplits,
+		CompletedSplits:    s.CompletedSplits,
+		ProcessedRows:      int64(s.ProcessedRows),
+		ProcessedBytes:     int64(s.ProcessedBytes),
+		PeakMemoryBytes:    s.PeakMemoryBytes,
+		SpilledBytes:       s.SpilledBytes,
+		CPUTime:            millis(s.CPUTimeMillis),
+		WallTime:           millis(s.WallTimeMillis),
+		QueuedTime:         millis(s.QueuedTimeMillis),
+		ElapsedTime:        millis(s.ElapsedTimeMillis),
+		progressPercentage: s.ProgressPercentage,
+	}
+	if s.RootStage.StageID != "" {
+		root := newStageProgress(&s.RootStage)
+		p.RootStage = &root
+	}
+	return p
+}
+
+func newStageProgress(s *stmtStage) StageProgress {
+	p := StageProgress{
+		StageID:         s.StageID,
+		State:           s.State,
+		Done:            s.Done,
+		Nodes:           s.Nodes,
+		TotalSplits:     s.TotalSplits,
+		QueuedSplits:    s.QueuedSplits,
+		RunningSplits:   s.RunningSplits,
+		CompletedSplits: s.CompletedSplits,
+		ProcessedRows:   int64(s.ProcessedRows),
+		ProcessedBytes:  int64(s.ProcessedBytes),
+		CPUTime:         millis(s.CPUTimeMillis),
+		WallTime:        millis(s.WallTimeMillis),
+	}
+	for i := range s.SubStages {
+		p.SubStages = append(p.SubStages, newStageProgress(&s.SubStages[i]))
+	}
+	return p
+}
+
+func millis(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// reportProgress passes the progress reported in a response to the query's progress
+// handler, if it has one.
+func (r *rows) reportProgress(s *stmtStats) {
+	h, ok := r.context().Value(progressKey).(ProgressHandler)
+	if !ok {
+		h = r.conn.onProgress
+	}
+	if h != nil {
+		h(newQueryProgress(r.id, s))
+	}
+}
@@ -0,0 +1,92 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var progressResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/statement":
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED", "queued": true}}`, r.Host)
+	case "/v1/query/abcd/1":
+		fmt.Fprintf(w, `{
+		  "id": "abcd",
+		  "nextUri": "http://%s/v1/query/abcd/2",
+		  "stats": {
+		    "state": "RUNNING", "scheduled": true, "nodes": 2,
+		    "totalSplits": 4, "queuedSplits": 1, "runningSplits": 2, "completedSplits": 1,
+		    "cpuTimeMillis": 1500, "wallTimeMillis": 3000, "processedRows": 100, "processedBytes": 2048,
+		    "peakMemoryBytes": 4096,
+		    "rootStage": {
+		      "stageId": "0", "state": "RUNNING", "totalSplits": 1, "completedSplits": 0,
+		      "subStages": [{"stageId": "1", "state": "RUNNING", "totalSplits": 3, "completedSplits": 1}]
+		    }
+		  }
+		}`, r.Host)
+	case "/v1/query/abcd/2":
+		r.URL.Path = "/v1/query/abcd/1"
+		oneRowColResponse(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestProgress(t *testing.T) {
+	ts := httptest.NewServer(progressResponse)
+	defer ts.Close()
+
+	var configured int
+	connector, err := NewConnector(Config{
+		DSN:          "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		PollStrategy: FixedPoll(0),
+		OnProgress:   func(QueryProgress) { configured++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+
+	var progress []QueryProgress
+	ctx := WithProgress(context.Background(), func(p QueryProgress) {
+		progress = append(progress, p)
+	})
+	r, err := cn.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Next(make([]driver.Value, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if configured != 0 {
+		t.Errorf("configured handler called %d times, wanted it to be overridden", configured)
+	}
+	if len(progress) != 3 {
+		t.Fatalf("got %d progress reports, wanted %d", len(progress), 3)
+	}
+
+	if p := progress[0]; p.State != QueryStateQueued || !p.Queued || p.RootStage != nil {
+		t.Errorf("got initial progress %+v", p)
+	}
+
+	p := progress[1]
+	if p.QueryID != "abcd" || p.State != QueryStateRunning || p.Nodes != 2 || p.ProcessedRows != 100 || p.ProcessedBytes != 2048 || p.PeakMemoryBytes != 4096 {
+		t.Errorf("got running progress %+v", p)
+	}
+	if p.CPUTime != 1500*time.Millisecond || p.WallTime != 3*time.Second {
+		t.Errorf("got cpu time %v and wall time %v", p.CPUTime, p.WallTime)
+	}
+	if p.PercentComplete() != 25 {
+		t.Errorf("got %v percent complete, wanted %v", p.PercentComplete(), 25)
+	}
+	if p.RootStage == nil || len(p.RootStage.SubStages) != 1 || p.RootStage.SubStages[0].TotalSplits != 3 {
+		t.Errorf("got stage tree %+v", p.RootStage)
+	}
+}
@@ -0,0 +1,92 @@
+package prestgo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Protocol identifies the dialect of the client protocol spoken by a server.
+type Protocol string
+
+const (
+	// ProtocolPresto is the protocol spoken by Presto, using X-Presto-* headers.
+	ProtocolPresto Protocol = "presto"
+
+	// ProtocolTrino is the protocol spoken by Trino, using X-Trino-* headers.
+	ProtocolTrino Protocol = "trino"
+
+	// ProtocolAuto detects the protocol from the server's version, reported by /v1/info,
+	// before the first query on a connection.
+	ProtocolAuto Protocol = "auto"
+)
+
+// firstTrinoVersion is the first release of Trino, which renamed the X-Presto-* headers.
+// Earlier releases of the same project were known as PrestoSQL.
+const firstTrinoVersion = 351
+
+func parseProtocol(s string) (Protocol, error) {
+	switch p := Protocol(strings.ToLower(s)); p {
+	case "":
+		return ProtocolPresto, nil
+	case ProtocolPresto, ProtocolTrino, ProtocolAuto:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%s: unknown protocol %q", DriverName, s)
+	}
+}
+
+// protocolForVersion returns the protocol spoken by a server reporting version.
+// Presto versions take the form 0.x while Trino's are a single release number,
+// optionally followed by a vendor suffix.
+func protocolForVersion(version string) Protocol {
+	end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	if end == -1 {
+		end = len(version)
+	}
+	if n, err := strconv.Atoi(version[:end]); err == nil && n >= firstTrinoVersion {
+		return ProtocolTrino
+	}
+	return ProtocolPresto
+}
+
+// resolveProtocol detects the protocol spoken by the server at addr if the connection
+// was configured with ProtocolAuto.
+func (c *conn) resolveProtocol(ctx context.Context, addr string) error {
+	if c.protocol != ProtocolAuto {
+		return nil
+	}
+
+	info, err := c.serverInfo(ctx, addr)
+	if err != nil {
+		return err
+	}
+	c.protocol = protocolForVersion(info.Version)
+	return nil
+}
+
+// header returns the protocol header with the given suffix, such as "User".
+func (c *conn) header(name string) string {
+	if c.protocol == ProtocolTrino {
+		return "X-Trino-" + name
+	}
+	return "X-Presto-" + name
+}
+
+var (
+	timestampPrecision = regexp.MustCompile(`^(timestamp|time)\(\d+\)`)
+	typeListSeparator  = regexp.MustCompile(`,\s+`)
+)
+
+// normalizeType maps the name of a column type onto the names used by Presto. Trino
+// includes the precision of time and timestamp types and separates type parameters
+// with a comma and space.
+func (c *conn) normalizeType(typ string) string {
+	if c.protocol != ProtocolTrino {
+		return typ
+	}
+	typ = timestampPrecision.ReplaceAllString(typ, "$1")
+	return typeListSeparator.ReplaceAllString(typ, ",")
+}
@@ -0,0 +1,170 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProtocolForVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected Protocol
+	}{
+		{version: "0.287", expected: ProtocolPresto},
+		{version: "0.215-SNAPSHOT", expected: ProtocolPresto},
+		{version: "350", expected: ProtocolPresto},
+		{version: "351", expected: ProtocolTrino},
+		{version: "435", expected: ProtocolTrino},
+		{version: "413-e.8", expected: ProtocolTrino},
+		{version: "", expected: ProtocolPresto},
+	}
+
+	for _, tc := range testCases {
+		if p := protocolForVersion(tc.version); p != tc.expected {
+			t.Errorf("%q: got %v, wanted %v", tc.version, p, tc.expected)
+		}
+	}
+}
+
+func TestNormalizeType(t *testing.T) {
+	testCases := []struct {
+		typ      string
+		expected string
+	}{
+		{typ: "timestamp(3)", expected: Timestamp},
+		{typ: "timestamp(6) with time zone", expected: TimestampWithTimezone},
+		{typ: "map(varchar, varchar)", expected: MapVarchar},
+		{typ: "array(varchar)", expected: ArrayVarchar},
+		{typ: "varchar(10)", expected: "varchar(10)"},
+		{typ: "bigint", expected: BigInt},
+	}
+
+	cn := &conn{protocol: ProtocolTrino}
+	for _, tc := range testCases {
+		if typ := cn.normalizeType(tc.typ); typ != tc.expected {
+			t.Errorf("%q: got %q, wanted %q", tc.typ, typ, tc.expected)
+		}
+	}
+}
+
+var trinoResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/info":
+		fmt.Fprint(w, `{"nodeVersion": {"version": "435"}, "environment": "test", "coordinator": true, "starting": false, "uptime": "1.00d"}`)
+	case "/v1/statement":
+		if r.Header.Get("X-Trino-User") == "" || r.Header.Get("X-Presto-User") != "" {
+			http.Error(w, "missing X-Trino-User", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED", "queued": true}}`, r.Host)
+	case "/v1/cluster":
+		if r.Header.Get("X-Trino-User") == "" {
+			http.Error(w, "missing X-Trino-User", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"runningQueries": 1, "activeCoordinators": 1, "activeWorkers": 2}`)
+	case "/v1/query/abcd":
+		if r.Header.Get("X-Trino-User") == "" || r.Method != "DELETE" {
+			http.Error(w, "missing X-Trino-User", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/v1/query/abcd/1":
+		if r.Header.Get("X-Trino-User") == "" {
+			http.Error(w, "missing X-Trino-User", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{
+		  "id": "abcd",
+		  "columns": [
+		    { "name": "col0", "type": "timestamp(6)", "typeSignature": { "rawType": "timestamp", "arguments": [{"kind": "LONG", "value": 6}] } },
+		    { "name": "col1", "type": "timestamp(3) with time zone", "typeSignature": { "rawType": "timestamp with time zone", "arguments": [{"kind": "LONG", "value": 3}] } },
+		    { "name": "col2", "type": "map(varchar, varchar)", "typeSignature": { "rawType": "map", "arguments": [] } }
+		  ],
+		  "data": [
+		    [ "2015-02-09 18:26:02.013456", "2015-02-09 18:26:02.013 +01:00", {"k": "v"} ]
+		  ],
+		  "stats": {"state": "FINISHED", "queued": false, "progressPercentage": 100}
+		}`)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestTrinoProtocolAuto(t *testing.T) {
+	ts := httptest.NewServer(trinoResponse)
+	defer ts.Close()
+
+	connector, err := NewConnector(Config{DSN: "presto://" + strings.TrimPrefix(ts.URL, "http://") + "?protocol=auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+	st, _ := cn.Prepare("SELECT 1")
+	r, err := st.Query(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cn.(*conn).protocol; p != ProtocolTrino {
+		t.Errorf("got protocol %v, wanted %v", p, ProtocolTrino)
+	}
+
+	values := make([]driver.Value, 3)
+	if err := r.Next(values); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []driver.Value{
+		time.Date(2015, 2, 9, 18, 26, 2, 13456000, time.Local),
+		time.Date(2015, 2, 9, 18, 26, 2, 13000000, time.FixedZone("+01:00", 3600)),
+		map[string]string{"k": "v"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %v, wanted %v", values, expected)
+	}
+}
+
+func TestClusterClientTrinoProtocolAuto(t *testing.T) {
+	ts := httptest.NewServer(trinoResponse)
+	defer ts.Close()
+
+	cc, err := NewClusterClient(Config{DSN: "presto://" + strings.TrimPrefix(ts.URL, "http://") + "?protocol=auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := cc.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ActiveWorkers != 2 {
+		t.Errorf("got %d active workers, wanted %d", stats.ActiveWorkers, 2)
+	}
+	if err := cc.KillQuery(context.Background(), "abcd", ""); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewConnectorUnknownProtocol(t *testing.T) {
+	if _, err := NewConnector(Config{DSN: "presto://example?protocol=mysql"}); err == nil {
+		t.Error("got no error, wanted one")
+	}
+	if _, err := NewConnector(Config{DSN: "presto://example?protocol=mysql", Protocol: ProtocolTrino}); err != nil {
+		t.Errorf("got %v, wanted no error", err)
+	}
+}
+
+func TestOpenUnknownProtocol(t *testing.T) {
+	if _, err := Open("presto://example?protocol=trinoo"); err == nil {
+		t.Error("got no error, wanted one")
+	}
+	if _, err := Open("presto://example?protocol=trino"); err != nil {
+		t.Errorf("got %v, wanted no error", err)
+	}
+}
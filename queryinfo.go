@@ -0,0 +1,29 @@
+package prestgo
+
+// QueryInfo identifies the query that produced a set of rows. It is implemented by the
+// driver.Rows returned when querying the driver's connections, including the rows of a
+// script, for which it describes the statement currently being read. database/sql
+// does not expose the driver.Rows behind a *sql.Rows, so the connection must be
+// queried directly, such as from within sql.Conn.Raw, to use it. Config.OnQueryStarted
+// reports the same information to code using database/sql.
+type QueryInfo interface {
+	// QueryID returns the id assigned to the query by the Presto server.
+	QueryID() string
+
+	// InfoURI returns the uri of the query's page in the Presto server's web interface.
+	InfoURI() string
+}
+
+// QueryStartedHandler is called when the Presto server has accepted a query, with the
+// query's id and the uri of its page in the server's web interface.
+type QueryStartedHandler func(queryID, infoURI string)
+
+var _ QueryInfo = &rows{}
+
+func (r *rows) QueryID() string {
+	return r.id
+}
+
+func (r *rows) InfoURI() string {
+	return r.infoURI
+}
@@ -0,0 +1,70 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var startedResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/statement":
+		fmt.Fprintf(w, `{"id": "20180131_000000_00001_abcde", "infoUri": "http://%[1]s/ui/query.html?20180131_000000_00001_abcde", "nextUri": "http://%[1]s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+	default:
+		oneRowColResponse(w, r)
+	}
+})
+
+func TestQueryInfo(t *testing.T) {
+	ts := httptest.NewServer(startedResponse)
+	defer ts.Close()
+
+	var started []string
+	connector, err := NewConnector(Config{
+		DSN: "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		OnQueryStarted: func(queryID, infoURI string) {
+			started = append(started, queryID, infoURI)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	cn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cn.Close()
+
+	var info QueryInfo
+	err = cn.Raw(func(dc interface{}) error {
+		r, err := dc.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+		if err != nil {
+			return err
+		}
+		info = r.(QueryInfo)
+		return r.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedID := "20180131_000000_00001_abcde"
+	expectedURI := ts.URL + "/ui/query.html?20180131_000000_00001_abcde"
+	if info.QueryID() != expectedID {
+		t.Errorf("got query id %q, wanted %q", info.QueryID(), expectedID)
+	}
+	if info.InfoURI() != expectedURI {
+		t.Errorf("got info uri %q, wanted %q", info.InfoURI(), expectedURI)
+	}
+	if len(started) != 2 || started[0] != expectedID || started[1] != expectedURI {
+		t.Errorf("got started %v, wanted [%s %s]", started, expectedID, expectedURI)
+	}
+}
@@ -0,0 +1,156 @@
+package prestgo
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultRetryPolicy is used when no RetryPolicy has been configured.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Jitter:         0.2,
+	Budget:         time.Minute,
+}
+
+// RetryPolicy controls how the driver resends requests that failed with a transient
+// error. Requests for pages of results are retried when the server responds with 502,
+// 503 or 504 or the request fails with a network error. The request that submits a
+// statement is only retried when it is known not to have reached the server: when the
+// server responds with 503 or the connection could not be established.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, including the first.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the time to wait before the first retry. The wait doubles with
+	// each subsequent retry, up to MaxBackoff if it is set.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of each wait that is randomized so that
+	// clients don't retry in lockstep.
+	Jitter float64
+
+	// Budget limits the total time spent retrying a single request. If zero, retries are
+	// limited only by MaxAttempts.
+	Budget time.Duration
+}
+
+// backoff returns the time to wait before the nth retry, counting from 1.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < n && (p.MaxBackoff == 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait -= time.Duration(p.Jitter * random.Float64() * float64(wait))
+	}
+	return wait
+}
+
+// lockedRand is a source of random numbers that may be used concurrently.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// random is seeded from the time, unlike the global source of math/rand, which
+// produces the same sequence in every process before Go 1.20. Clients started together
+// would otherwise retry in lockstep and try coordinators in the same random order.
+var random = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+func (lr *lockedRand) Float64() float64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Float64()
+}
+
+func (lr *lockedRand) Perm(n int) []int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Perm(n)
+}
+
+// retryable reports whether a request that produced resp or err may be resent.
+// Statement submissions are not idempotent, so are only resent when the server can't
+// have started executing them.
+func retryable(idempotent bool, resp *http.Response, err error) bool {
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return idempotent
+	}
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// doRetry sends req to the server, resending it according to the connection's retry
+// policy. The number of times the request was resent is added to retries.
+func (c *conn) doRetry(req *http.Request, idempotent bool, retries *int) (*http.Response, error) {
+	policy := c.retryPolicy
+	if policy == nil {
+		policy = &DefaultRetryPolicy
+	}
+
+	ctx := req.Context()
+	var deadline time.Time
+	if policy.Budget > 0 {
+		deadline = time.Now().Add(policy.Budget)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(req)
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !retryable(idempotent, resp, err) {
+			return resp, err
+		}
+
+		wait := policy.backoff(attempt)
+		if !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+		c.logRetry(req, attempt, wait, resp, err)
+		c.count(MetricRetries, 1)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+
+		retry := req.Clone(ctx)
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		req = retry
+		*retries++
+	}
+}
+
+// logRetry logs the failure of a request that is about to be resent.
+func (c *conn) logRetry(req *http.Request, attempt int, wait time.Duration, resp *http.Response, err error) {
+	args := []interface{}{"method", req.Method, "path", req.URL.Path, "attempt", attempt, "wait", wait}
+	if err != nil {
+		args = append(args, "error", err)
+	} else {
+		args = append(args, "status", resp.StatusCode)
+	}
+	c.log(req.Context(), levelWarn, "retrying request", args...)
+}
@@ -0,0 +1,116 @@
+package prestgo
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, want := range expected {
+		if got := p.backoff(i + 1); got != want {
+			t.Errorf("retry %d: got %v, wanted %v", i+1, got, want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("got %v, wanted between 50ms and 100ms", got)
+		}
+	}
+}
+
+// flakyServer fails the first failures requests to path with the given status.
+func flakyServer(path string, status, failures int) http.HandlerFunc {
+	var n int
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case path:
+			if n < failures {
+				n++
+				w.WriteHeader(status)
+				return
+			}
+		}
+		switch r.URL.Path {
+		case "/v1/statement":
+			fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+		default:
+			oneRowColResponse(w, r)
+		}
+	}
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		status   int
+		failures int
+		retries  int
+		err      bool
+	}{
+		{name: "page 503", path: "/v1/query/abcd/1", status: 503, failures: 2, retries: 2},
+		{name: "page 502", path: "/v1/query/abcd/1", status: 502, failures: 1, retries: 1},
+		{name: "page 504", path: "/v1/query/abcd/1", status: 504, failures: 1, retries: 1},
+		{name: "page 500", path: "/v1/query/abcd/1", status: 500, failures: 1, err: true},
+		{name: "page exhausted", path: "/v1/query/abcd/1", status: 503, failures: 3, err: true},
+		{name: "statement 503", path: "/v1/statement", status: 503, failures: 2, retries: 2},
+		{name: "statement 502", path: "/v1/statement", status: 502, failures: 1, err: true},
+	}
+
+	for _, tc := range testCases {
+		ts := httptest.NewServer(flakyServer(tc.path, tc.status, tc.failures))
+
+		cn := &conn{
+			client:      http.DefaultClient,
+			hosts:       newHostPool(config{"addr": strings.TrimPrefix(ts.URL, "http://")}),
+			retryPolicy: &RetryPolicy{MaxAttempts: 3},
+		}
+		st, _ := cn.Prepare("SELECT 1")
+		r, err := st.Query(nil)
+		if err == nil {
+			err = r.Next(make([]driver.Value, 1))
+		}
+		ts.Close()
+
+		if tc.err != (err != nil) {
+			t.Errorf("%s: got error %v, wanted %v", tc.name, err, tc.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if retries := r.(StatsProvider).Stats().Retries; retries != tc.retries {
+			t.Errorf("%s: got %d retries, wanted %d", tc.name, retries, tc.retries)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	ts := httptest.NewServer(flakyServer("/v1/query/abcd/1", 503, 10))
+	defer ts.Close()
+
+	r := &rows{
+		conn: &conn{
+			client:      http.DefaultClient,
+			retryPolicy: &RetryPolicy{MaxAttempts: 10, InitialBackoff: 20 * time.Millisecond, Budget: 50 * time.Millisecond},
+		},
+		nextURI: ts.URL + "/v1/query/abcd/1",
+	}
+	if err := r.fetch(); err == nil {
+		t.Fatal("got no error, wanted one")
+	}
+	// Waits of 20ms and 40ms exceed the budget after the first retry
+	if r.stats.Retries != 1 {
+		t.Errorf("got %d retries, wanted %d", r.stats.Retries, 1)
+	}
+}
@@ -0,0 +1,234 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sqlToken is a kind of token found in the text of a query.
+type sqlToken int
+
+const (
+	sqlCode sqlToken = iota
+	sqlSpace
+	sqlString
+	sqlIdentifier
+	sqlComment
+	sqlSemicolon
+)
+
+// scanSQL returns the kind and length of the token at the start of s, which must not
+// be empty. Tokens of code and space are a single byte long. String literals, quoted
+// identifiers and comments that are not terminated extend to the end of s.
+func scanSQL(s string) (sqlToken, int) {
+	switch c := s[0]; {
+	case c == '\'' || c == '"':
+		kind := sqlString
+		if c == '"' {
+			kind = sqlIdentifier
+		}
+		// A doubled quote escapes a quote within the literal or identifier
+		for n := 1; n < len(s); n++ {
+			if s[n] != c {
+				continue
+			}
+			if n+1 < len(s) && s[n+1] == c {
+				n++
+				continue
+			}
+			return kind, n + 1
+		}
+		return kind, len(s)
+	case strings.HasPrefix(s, "--"):
+		if n := strings.IndexByte(s, '\n'); n >= 0 {
+			return sqlComment, n
+		}
+		return sqlComment, len(s)
+	case strings.HasPrefix(s, "/*"):
+		if n := strings.Index(s[2:], "*/"); n >= 0 {
+			return sqlComment, n + 4
+		}
+		return sqlComment, len(s)
+	case c == ';':
+		return sqlSemicolon, 1
+	case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		return sqlSpace, 1
+	default:
+		return sqlCode, 1
+	}
+}
+
+// SplitStatements splits a script into its statements, which are separated by
+// semicolons. Semicolons within string literals, quoted identifiers and comments do
+// not separate statements. The statements are trimmed of surrounding space and those
+// that are empty or hold only comments are omitted.
+func SplitStatements(script string) []string {
+	var stmts []string
+	var start int
+	var code bool // whether the current statement holds more than comments
+
+	add := func(end int) {
+		if code {
+			stmts = append(stmts, strings.TrimSpace(script[start:end]))
+		}
+		start, code = end+1, false
+	}
+
+	for i := 0; i < len(script); {
+		kind, n := scanSQL(script[i:])
+		switch kind {
+		case sqlSemicolon:
+			add(i)
+		case sqlCode, sqlString, sqlIdentifier:
+			code = true
+		}
+		i += n
+	}
+	add(len(script))
+	return stmts
+}
+
+// ScriptError is returned when a statement of a script fails.
+type ScriptError struct {
+	// Index is the position of the statement in the script, counting from zero.
+	Index     int
+	Statement string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s: statement %d of script failed: %v", DriverName, e.Index+1, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ExecScript runs the statements of script, as split by SplitStatements, one after
+// another on c, discarding any rows they return. Changes made by a statement to the
+// session, such as by USE, SET SESSION or PREPARE, apply to the statements that
+// follow it and to later queries on c. It stops at the first statement that fails,
+// returning a ScriptError.
+func ExecScript(ctx context.Context, c *sql.Conn, script string) error {
+	for i, stmt := range SplitStatements(script) {
+		if err := drainQuery(ctx, c, stmt); err != nil {
+			return &ScriptError{Index: i, Statement: stmt, Err: err}
+		}
+	}
+	return nil
+}
+
+func drainQuery(ctx context.Context, c *sql.Conn, query string) error {
+	rows, err := c.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// scriptRows returns the results of a query holding several statements as separate
+// result sets, when the connection's Config enables MultiStatement. Each statement
+// is started once the results of the one before it have been read, so that it sees
+// the changes that statement made to the session.
+type scriptRows struct {
+	stmt  *stmt
+	ctx   context.Context
+	stmts []string
+	index int
+	rows  *rows
+}
+
+var (
+	_ driver.RowsNextResultSet = &scriptRows{}
+	_ QueryInfo                = &scriptRows{}
+	_ StatsProvider            = &scriptRows{}
+	_ WarningsProvider         = &scriptRows{}
+	_ TruncationProvider       = &scriptRows{}
+)
+
+// runScript starts the first of stmts.
+func (s *stmt) runScript(ctx context.Context, stmts []string) (driver.Rows, error) {
+	r, err := s.runQuery(ctx, stmts[0])
+	if err != nil {
+		return nil, &ScriptError{Index: 0, Statement: stmts[0], Err: err}
+	}
+	return &scriptRows{stmt: s, ctx: ctx, stmts: stmts, rows: r}, nil
+}
+
+func (sr *scriptRows) wrap(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return &ScriptError{Index: sr.index, Statement: sr.stmts[sr.index], Err: err}
+}
+
+func (sr *scriptRows) Columns() []string {
+	return sr.rows.Columns()
+}
+
+func (sr *scriptRows) Next(dest []driver.Value) error {
+	return sr.wrap(sr.rows.Next(dest))
+}
+
+func (sr *scriptRows) Close() error {
+	return sr.rows.Close()
+}
+
+// QueryID, InfoURI, Stats, Warnings and Truncated describe the statement currently
+// being read.
+
+func (sr *scriptRows) QueryID() string {
+	return sr.rows.QueryID()
+}
+
+func (sr *scriptRows) InfoURI() string {
+	return sr.rows.InfoURI()
+}
+
+func (sr *scriptRows) Stats() QueryStats {
+	return sr.rows.Stats()
+}
+
+func (sr *scriptRows) Warnings() []Warning {
+	return sr.rows.Warnings()
+}
+
+func (sr *scriptRows) Truncated() bool {
+	return sr.rows.Truncated()
+}
+
+func (sr *scriptRows) HasNextResultSet() bool {
+	return sr.index < len(sr.stmts)-1
+}
+
+// NextResultSet reads the remaining rows of the current statement, so that it
+// completes, and starts the next.
+func (sr *scriptRows) NextResultSet() error {
+	if !sr.HasNextResultSet() {
+		return io.EOF
+	}
+	dest := make([]driver.Value, len(sr.rows.Columns()))
+	var err error
+	for err == nil {
+		err = sr.rows.Next(dest)
+	}
+	sr.rows.Close()
+	if err != io.EOF {
+		return sr.wrap(err)
+	}
+
+	sr.index++
+	r, err := sr.stmt.runQuery(sr.ctx, sr.stmts[sr.index])
+	if err != nil {
+		return sr.wrap(err)
+	}
+	sr.rows = r
+	return nil
+}
@@ -0,0 +1,251 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		script   string
+		expected []string
+	}{
+		{script: "", expected: nil},
+		{script: "SELECT 1", expected: []string{"SELECT 1"}},
+		{script: "SELECT 1;", expected: []string{"SELECT 1"}},
+		{script: " SELECT 1 ;\n SELECT 2 ", expected: []string{"SELECT 1", "SELECT 2"}},
+		{script: "SELECT 1;;\n;SELECT 2", expected: []string{"SELECT 1", "SELECT 2"}},
+		{script: "SELECT 'a;b'; SELECT 2", expected: []string{"SELECT 'a;b'", "SELECT 2"}},
+		{script: "SELECT 'it''s;'; SELECT 2", expected: []string{"SELECT 'it''s;'", "SELECT 2"}},
+		{script: `SELECT "a;""b" FROM t; SELECT 2`, expected: []string{`SELECT "a;""b" FROM t`, "SELECT 2"}},
+		{script: "SELECT 1 -- one; two\n; SELECT 2", expected: []string{"SELECT 1 -- one; two", "SELECT 2"}},
+		{script: "SELECT /* one; two */ 1; SELECT 2", expected: []string{"SELECT /* one; two */ 1", "SELECT 2"}},
+		{script: "-- setup;\n/* nothing; */;\nSELECT 1; -- done", expected: []string{"SELECT 1"}},
+		{script: "SELECT 'unterminated; SELECT 2", expected: []string{"SELECT 'unterminated; SELECT 2"}},
+	}
+
+	for _, tc := range testCases {
+		got := SplitStatements(tc.script)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%q: got %q, wanted %q", tc.script, got, tc.expected)
+		}
+	}
+}
+
+// scriptServer runs statements that change the session, recording the session sent
+// with each statement.
+type scriptServer struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (s *scriptServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/statement" {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.received = append(s.received, fmt.Sprintf("%s [%s.%s %s %s]", body,
+			r.Header.Get("X-Presto-Catalog"), r.Header.Get("X-Presto-Schema"),
+			strings.Join(r.Header["X-Presto-Session"], ","), strings.Join(r.Header["X-Presto-Prepared-Statement"], ",")))
+		s.mu.Unlock()
+		kw := keyword(string(body))
+		fmt.Fprintf(w, `{"id": "%s", "nextUri": "http://%s/v1/query/abcd/1?q=%s", "stats": {"state": "QUEUED"}}`, strings.ToLower(kw), r.Host, kw)
+		return
+	}
+
+	switch r.URL.Query().Get("q") {
+	case "USE":
+		w.Header().Set("X-Presto-Set-Catalog", "hive")
+		w.Header().Set("X-Presto-Set-Schema", "web")
+	case "SET":
+		w.Header().Add("X-Presto-Set-Session", "query_max_memory=1GB")
+		w.Header().Add("X-Presto-Set-Session", "time_zone=Europe%2FLondon")
+	case "RESET":
+		w.Header().Add("X-Presto-Clear-Session", "query_max_memory")
+	case "PREPARE":
+		w.Header().Add("X-Presto-Added-Prepare", "q1=SELECT+%3F")
+	case "DEALLOCATE":
+		w.Header().Add("X-Presto-Deallocated-Prepare", "q1")
+	case "FAIL":
+		fmt.Fprint(w, `{"id": "abcd", "error": {"message": "failed"}, "stats": {"state": "FAILED"}}`)
+		return
+	}
+	fmt.Fprint(w, `{"id": "abcd", "columns": [{"name": "result", "type": "bigint"}], "data": [[1], [2]], "stats": {"state": "FINISHED"}}`)
+}
+
+// keyword returns the first word of a statement, after any comment lines.
+func keyword(stmt string) string {
+	for _, line := range strings.Split(stmt, "\n") {
+		if line = strings.TrimSpace(line); !strings.HasPrefix(line, "--") {
+			return strings.Fields(line)[0]
+		}
+	}
+	return ""
+}
+
+func (s *scriptServer) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received
+}
+
+func scriptConn(t *testing.T, s *scriptServer, conf Config) (*sql.Conn, func()) {
+	ts := httptest.NewServer(s)
+	conf.DSN = "presto://" + strings.TrimPrefix(ts.URL, "http://") + "/default/base?session=a=b"
+	connector, err := NewConnector(conf)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	c, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		c.Close()
+		db.Close()
+		ts.Close()
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	s := &scriptServer{}
+	c, done := scriptConn(t, s, Config{})
+	defer done()
+
+	script := `USE hive.web;
+		SET SESSION query_max_memory = '1GB'; -- and time_zone
+		PREPARE q1 FROM SELECT ?;
+		SELECT 1;
+		DEALLOCATE PREPARE q1;
+		RESET SESSION query_max_memory;
+		SELECT 2`
+	if err := ExecScript(context.Background(), c, script); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"USE hive.web [default.base a=b ]",
+		"SET SESSION query_max_memory = '1GB' [hive.web a=b ]",
+		"-- and time_zone\n\t\tPREPARE q1 FROM SELECT ? [hive.web a=b,query_max_memory=1GB,time_zone=Europe%2FLondon ]",
+		"SELECT 1 [hive.web a=b,query_max_memory=1GB,time_zone=Europe%2FLondon q1=SELECT+%3F]",
+		"DEALLOCATE PREPARE q1 [hive.web a=b,query_max_memory=1GB,time_zone=Europe%2FLondon q1=SELECT+%3F]",
+		"RESET SESSION query_max_memory [hive.web a=b,query_max_memory=1GB,time_zone=Europe%2FLondon ]",
+		"SELECT 2 [hive.web a=b,time_zone=Europe%2FLondon ]",
+	}
+	if got := s.statements(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got statements\n%s\nwanted\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestExecScriptError(t *testing.T) {
+	s := &scriptServer{}
+	c, done := scriptConn(t, s, Config{})
+	defer done()
+
+	err := ExecScript(context.Background(), c, "USE hive.web; FAIL; SELECT 1")
+	var serr *ScriptError
+	if !errors.As(err, &serr) {
+		t.Fatalf("got %v, wanted a ScriptError", err)
+	}
+	if serr.Index != 1 || serr.Statement != "FAIL" {
+		t.Errorf("got statement %d %q, wanted 1 %q", serr.Index, serr.Statement, "FAIL")
+	}
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Message != "failed" {
+		t.Errorf("got %v, wanted the query's error", err)
+	}
+	if n := len(s.statements()); n != 2 {
+		t.Errorf("got %d statements run, wanted 2", n)
+	}
+}
+
+func TestScriptResultSets(t *testing.T) {
+	s := &scriptServer{}
+	c, done := scriptConn(t, s, Config{MultiStatement: true})
+	defer done()
+
+	rows, err := c.QueryContext(context.Background(), "SELECT 1; USE hive.web; SELECT 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var sets [][]int64
+	for {
+		var set []int64
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			set = append(set, v)
+		}
+		sets = append(sets, set)
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := [][]int64{{1, 2}, {1, 2}, {1, 2}}; !reflect.DeepEqual(sets, expected) {
+		t.Errorf("got result sets %v, wanted %v", sets, expected)
+	}
+	got := s.statements()
+	if len(got) != 3 || got[2] != "SELECT 3 [hive.web a=b ]" {
+		t.Errorf("got statements %q, wanted the session to be carried to the last", got)
+	}
+}
+
+func TestScriptQueryInfo(t *testing.T) {
+	s := &scriptServer{}
+	c, done := scriptConn(t, s, Config{MultiStatement: true})
+	defer done()
+
+	var ids []string
+	err := c.Raw(func(dc interface{}) error {
+		r, err := dc.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1; USE hive.web", nil)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		ids = append(ids, r.(QueryInfo).QueryID())
+		if err := r.(driver.RowsNextResultSet).NextResultSet(); err != nil {
+			return err
+		}
+		ids = append(ids, r.(QueryInfo).QueryID())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"select", "use"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("got query ids %q, wanted %q", ids, expected)
+	}
+}
+
+func TestQuerySingleStatement(t *testing.T) {
+	s := &scriptServer{}
+	c, done := scriptConn(t, s, Config{})
+	defer done()
+
+	query := "CREATE FUNCTION one() RETURNS bigint BEGIN DECLARE x bigint DEFAULT 1; RETURN x; END"
+	if err := drainQuery(context.Background(), c, query); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := s.statements(), []string{query + " [default.base a=b ]"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got statements %q, wanted %q", got, expected)
+	}
+}
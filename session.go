@@ -0,0 +1,73 @@
+package prestgo
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// updateSession applies the changes to the connection's session that the server
+// reports in the headers of a response, such as those made by USE, SET SESSION and
+// PREPARE statements, so that they affect later queries on the connection.
+func (c *conn) updateSession(h http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v := h.Get(c.header("Set-Catalog")); v != "" {
+		c.catalog = v
+	}
+	if v := h.Get(c.header("Set-Schema")); v != "" {
+		c.schema = v
+	}
+	for _, v := range h[c.header("Set-Session")] {
+		name, value := splitPair(v)
+		if c.session == nil {
+			c.session = make(map[string]string)
+		}
+		c.session[name] = value
+	}
+	for _, name := range h[c.header("Clear-Session")] {
+		delete(c.session, name)
+	}
+	for _, v := range h[c.header("Added-Prepare")] {
+		name, value := splitPair(v)
+		if c.prepared == nil {
+			c.prepared = make(map[string]string)
+		}
+		c.prepared[name] = value
+	}
+	for _, name := range h[c.header("Deallocated-Prepare")] {
+		delete(c.prepared, name)
+	}
+}
+
+// setSessionHeaders sends the connection's catalog, schema, session properties and
+// prepared statements. properties holds the session properties of a single query,
+// which take precedence over those of the connection.
+func (c *conn) setSessionHeaders(req *http.Request, properties map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req.Header.Add(c.header("Catalog"), c.catalog)
+	req.Header.Add(c.header("Schema"), c.schema)
+	session := mergePairs(c.session, properties)
+	for _, k := range sortedKeys(session) {
+		req.Header.Add(c.header("Session"), k+"="+url.QueryEscape(session[k]))
+	}
+	for _, k := range sortedKeys(c.prepared) {
+		req.Header.Add(c.header("Prepared-Statement"), k+"="+url.QueryEscape(c.prepared[k]))
+	}
+}
+
+// splitPair splits a name=value header, decoding the value.
+func splitPair(s string) (string, string) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) < 2 {
+		return strings.TrimSpace(kv[0]), ""
+	}
+	value, err := url.QueryUnescape(kv[1])
+	if err != nil {
+		value = kv[1]
+	}
+	return strings.TrimSpace(kv[0]), value
+}
@@ -0,0 +1,248 @@
+package prestgo
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxSegmentFetches is the number of spooled segments of a page that are downloaded
+// at the same time.
+const maxSegmentFetches = 4
+
+// Decompressor returns a reader of the decompressed contents of r. Decompressors may
+// be added to a Config to support spooled segments using compressed encodings such
+// as json+zstd. Only segments whose metadata gives an uncompressed size are
+// decompressed, since servers leave small segments uncompressed. If the returned
+// reader is an io.Closer it is closed once the segment has been read.
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+// spooledData describes the data of a page returned using the spooling protocol.
+type spooledData struct {
+	Encoding string    `json:"encoding"`
+	Segments []segment `json:"segments"`
+}
+
+// segment holds a number of rows, either inline or at a uri they must be downloaded
+// from.
+type segment struct {
+	Type     string              `json:"type"`
+	Data     []byte              `json:"data"` // base64 in JSON
+	URI      string              `json:"uri"`
+	AckURI   string              `json:"ackUri"`
+	Headers  map[string][]string `json:"headers"`
+	Metadata segmentMetadata     `json:"metadata"`
+}
+
+type segmentMetadata struct {
+	RowOffset        int64 `json:"rowOffset"`
+	RowsCount        int64 `json:"rowsCount"`
+	SegmentSize      int64 `json:"segmentSize"`
+	UncompressedSize int64 `json:"uncompressedSize"`
+}
+
+const (
+	segmentInline  = "inline"
+	segmentSpooled = "spooled"
+)
+
+// decodeSpooledData decodes the fields of a spooled data object whose opening
+// delimiter has been read.
+func decodeSpooledData(dec *json.Decoder) (*spooledData, error) {
+	var sd spooledData
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		switch tok {
+		case "encoding":
+			v = &sd.Encoding
+		case "segments":
+			v = &sd.Segments
+		default:
+			v = &json.RawMessage{}
+		}
+		if err := dec.Decode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return &sd, nil
+}
+
+// queryDataEncodings returns the value of the header that asks the server to use the
+// spooling protocol, listing the supported encodings in order of preference.
+func (c *conn) queryDataEncodings() string {
+	var encodings []string
+	for name := range c.segmentDecompressors {
+		encodings = append(encodings, "json+"+name)
+	}
+	sort.Strings(encodings)
+	return strings.Join(append(encodings, "json"), ",")
+}
+
+func (c *conn) segmentDecompressor(encoding string) (Decompressor, error) {
+	if encoding == "json" {
+		return nil, nil
+	}
+	if strings.HasPrefix(encoding, "json+") {
+		if d, ok := c.segmentDecompressors[strings.TrimPrefix(encoding, "json+")]; ok {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: unsupported spooled encoding %q", DriverName, encoding)
+}
+
+// loadSegments decodes the rows of each segment of a page, downloading spooled
+// segments in parallel. The rows are returned in the order of their segments.
+func (r *rows) loadSegments(sd *spooledData) ([][]driver.Value, error) {
+	decompress, err := r.conn.segmentDecompressor(sd.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(r.context())
+	defer cancel()
+
+	results := make([][][]driver.Value, len(sd.Segments))
+	errs := make([]error, len(sd.Segments))
+	sem := make(chan struct{}, maxSegmentFetches)
+	var wg sync.WaitGroup
+	for i := range sd.Segments {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = r.loadSegment(ctx, &sd.Segments[i], decompress)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var data [][]driver.Value
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		data = append(data, results[i]...)
+	}
+	return data, nil
+}
+
+func (r *rows) loadSegment(ctx context.Context, s *segment, decompress Decompressor) ([][]driver.Value, error) {
+	var body io.Reader
+	var received *countingReader
+	switch s.Type {
+	case segmentInline:
+		body = bytes.NewReader(s.Data)
+	case segmentSpooled:
+		resp, err := r.fetchSegment(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		received = &countingReader{r: resp.Body}
+		body = received
+	default:
+		return nil, fmt.Errorf("%s: unsupported segment type %q", DriverName, s.Type)
+	}
+
+	if decompress != nil && s.Metadata.UncompressedSize > 0 {
+		var err error
+		if body, err = decompress(body); err != nil {
+			return nil, err
+		}
+		if c, ok := body.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+	decoded := &countingReader{r: body}
+
+	dec := json.NewDecoder(decoded)
+	dec.UseNumber()
+	data, err := r.decodeRows(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if received != nil {
+		io.Copy(ioutil.Discard, decoded)
+		r.mu.Lock()
+		r.stats.ReceivedBytes += received.n
+		r.stats.DecodedBytes += decoded.n
+		r.mu.Unlock()
+		r.conn.count(MetricBytesReceived, received.n)
+	}
+
+	if s.AckURI != "" {
+		r.acknowledgeSegment(ctx, s)
+	}
+	return data, nil
+}
+
+// fetchSegment downloads a spooled segment. Segments may be served by storage other
+// than the Presto server so the request carries only the headers supplied with the
+// segment, not the driver's credentials.
+func (r *rows) fetchSegment(ctx context.Context, s *segment) (*http.Response, error) {
+	req, err := newSegmentRequest(ctx, s, s.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.conn.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		err := newHTTPError(resp)
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// acknowledgeSegment tells the server that a segment has been read and may be
+// deleted. Failures are ignored since the server removes unacknowledged segments
+// once the query completes.
+func (r *rows) acknowledgeSegment(ctx context.Context, s *segment) {
+	req, err := newSegmentRequest(ctx, s, s.AckURI)
+	if err != nil {
+		return
+	}
+	resp, err := r.conn.client.Do(req)
+	if err != nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// newSegmentRequest returns a request for uri, which is the location or the
+// acknowledgement uri of s, carrying the headers supplied with the segment.
+func newSegmentRequest(ctx context.Context, s *segment, uri string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range s.Headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	return req.WithContext(ctx), nil
+}
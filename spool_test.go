@@ -0,0 +1,207 @@
+package prestgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// spoolingServer serves a query whose single page of results is split into an
+// inline segment and two spooled segments when the client asks for spooling. If
+// compress is set the segments are compressed, except for the second spooled segment
+// when mixed is also set.
+type spoolingServer struct {
+	compress bool
+	mixed    bool
+
+	mu        sync.Mutex
+	encodings string
+	acked     []string
+}
+
+// encode returns the contents of the segment holding body and the metadata fields
+// that describe its compression.
+func (s *spoolingServer) encode(segment int, body string) ([]byte, string) {
+	if !s.compress || (s.mixed && segment == 2) {
+		return []byte(body), ""
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	io.WriteString(gw, body)
+	gw.Close()
+	return buf.Bytes(), fmt.Sprintf(`, "uncompressedSize": %d`, len(body))
+}
+
+func (s *spoolingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/statement":
+		s.mu.Lock()
+		s.encodings = r.Header.Get("X-Trino-Query-Data-Encoding")
+		s.mu.Unlock()
+		fmt.Fprintf(w, `{"id": "abcd", "nextUri": "http://%s/v1/query/abcd/1", "stats": {"state": "QUEUED"}}`, r.Host)
+	case r.URL.Path == "/v1/query/abcd/1":
+		s.mu.Lock()
+		spooling := s.encodings != ""
+		s.mu.Unlock()
+
+		columns := `"columns": [{"name": "n", "type": "bigint"}, {"name": "s", "type": "varchar"}]`
+		if !spooling {
+			fmt.Fprintf(w, `{"id": "abcd", %s, "data": [[1, "a"], [2, "b"], [3, "c"], [4, "d"]], "stats": {"state": "FINISHED"}}`, columns)
+			return
+		}
+
+		encoding := "json"
+		if s.compress {
+			encoding = "json+gzip"
+		}
+		inline, inlineMeta := s.encode(0, `[[1, "a"], [2, "b"]]`)
+		_, meta1 := s.encode(1, `[[3, "c"]]`)
+		_, meta2 := s.encode(2, `[[4, "d"]]`)
+		fmt.Fprintf(w, `{
+		  "id": "abcd",
+		  %s,
+		  "data": {
+		    "encoding": "%s",
+		    "segments": [
+		      {"type": "inline", "data": "%s", "metadata": {"rowOffset": 0, "rowsCount": 2%s}},
+		      {"type": "spooled", "uri": "http://%s/v1/spooled/1", "ackUri": "http://%s/v1/spooled/1/ack", "headers": {"X-Segment-Token": ["secret"]}, "metadata": {"rowOffset": 2, "rowsCount": 1%s}},
+		      {"type": "spooled", "uri": "http://%s/v1/spooled/2", "ackUri": "http://%s/v1/spooled/2/ack", "headers": {"X-Segment-Token": ["secret"]}, "metadata": {"rowOffset": 3, "rowsCount": 1%s}}
+		    ]
+		  },
+		  "stats": {"state": "FINISHED"}
+		}`, columns, encoding, base64.StdEncoding.EncodeToString(inline), inlineMeta, r.Host, r.Host, meta1, r.Host, r.Host, meta2)
+	case r.URL.Path == "/v1/spooled/1" || r.URL.Path == "/v1/spooled/2":
+		if r.Header.Get("X-Segment-Token") != "secret" {
+			http.Error(w, "missing segment token", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/v1/spooled/1" {
+			data, _ := s.encode(1, `[[3, "c"]]`)
+			w.Write(data)
+		} else {
+			data, _ := s.encode(2, `[[4, "d"]]`)
+			w.Write(data)
+		}
+	case strings.HasSuffix(r.URL.Path, "/ack"):
+		if r.Header.Get("X-Segment-Token") != "secret" {
+			http.Error(w, "missing segment token", http.StatusForbidden)
+			return
+		}
+		s.mu.Lock()
+		s.acked = append(s.acked, r.URL.Path)
+		s.mu.Unlock()
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// closeCounter counts the times a decompressed segment reader is closed.
+type closeCounter struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (c closeCounter) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return c.ReadCloser.Close()
+}
+
+func TestSpooling(t *testing.T) {
+	var closed int32
+	gunzip := func(r io.Reader) (io.Reader, error) {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return closeCounter{ReadCloser: gr, closed: &closed}, nil
+	}
+
+	testCases := []struct {
+		name          string
+		protocol      Protocol
+		spooling      bool
+		compress      bool
+		mixed         bool
+		decompressors map[string]Decompressor
+		encodings     string
+		acked         int
+		closed        int32
+	}{
+		{name: "spooled", protocol: ProtocolTrino, spooling: true, encodings: "json", acked: 2},
+		{name: "compressed", protocol: ProtocolTrino, spooling: true, compress: true, decompressors: map[string]Decompressor{"gzip": gunzip}, encodings: "json+gzip,json", acked: 2, closed: 3},
+		{name: "mixed", protocol: ProtocolTrino, spooling: true, compress: true, mixed: true, decompressors: map[string]Decompressor{"gzip": gunzip}, encodings: "json+gzip,json", acked: 2, closed: 2},
+		{name: "not requested", protocol: ProtocolTrino},
+		{name: "presto", protocol: ProtocolPresto, spooling: true},
+	}
+
+	for _, tc := range testCases {
+		atomic.StoreInt32(&closed, 0)
+		s := &spoolingServer{compress: tc.compress, mixed: tc.mixed}
+		ts := httptest.NewServer(s)
+
+		connector, err := NewConnector(Config{
+			DSN:                  "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+			Protocol:             tc.protocol,
+			Spooling:             tc.spooling,
+			SegmentDecompressors: tc.decompressors,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cn, _ := connector.Connect(context.Background())
+		r, err := cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got [][]driver.Value
+		for {
+			dest := make([]driver.Value, 2)
+			if err := r.Next(dest); err != nil {
+				if err != io.EOF {
+					t.Errorf("%s: got error %v", tc.name, err)
+				}
+				break
+			}
+			got = append(got, dest)
+		}
+		r.Close()
+		ts.Close()
+
+		expected := [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}, {int64(4), "d"}}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: got %v, wanted %v", tc.name, got, expected)
+		}
+		if s.encodings != tc.encodings {
+			t.Errorf("%s: got encodings %q, wanted %q", tc.name, s.encodings, tc.encodings)
+		}
+		if len(s.acked) != tc.acked {
+			t.Errorf("%s: got %d segments acknowledged, wanted %d", tc.name, len(s.acked), tc.acked)
+		}
+		if n := atomic.LoadInt32(&closed); n != tc.closed {
+			t.Errorf("%s: got %d decompressors closed, wanted %d", tc.name, n, tc.closed)
+		}
+	}
+}
+
+func TestSpoolingUnsupportedEncoding(t *testing.T) {
+	r := &rows{conn: &conn{}}
+	qresp, err := r.decodeResponse(strings.NewReader(`{"columns": [{"name": "n", "type": "bigint"}], "data": {"encoding": "json+zstd", "segments": []}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.loadSegments(qresp.Spooled); err == nil {
+		t.Errorf("got no error for unsupported encoding")
+	}
+}
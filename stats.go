@@ -0,0 +1,29 @@
+package prestgo
+
+// QueryStats holds statistics gathered by the driver while executing a query.
+type QueryStats struct {
+	// Retries is the number of requests that were resent after a transient failure.
+	Retries int
+
+	// ReceivedBytes is the number of bytes of result pages received from the server,
+	// which may be compressed. DecodedBytes is the number of bytes after
+	// decompression. They are equal when the server does not compress its responses.
+	ReceivedBytes int64
+	DecodedBytes  int64
+}
+
+// StatsProvider reports the statistics gathered while fetching the results of a query.
+// Like QueryInfo, it is implemented by the driver.Rows returned by the driver's
+// connections.
+type StatsProvider interface {
+	// Stats returns the statistics of the query that produced the rows.
+	Stats() QueryStats
+}
+
+var _ StatsProvider = &rows{}
+
+func (r *rows) Stats() QueryStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
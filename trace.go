@@ -0,0 +1,158 @@
+package prestgo
+
+import "context"
+
+// Names of the spans reported to a Tracer.
+const (
+	// SpanSubmit covers sending a query to the server, including any retries.
+	SpanSubmit = "presto.submit"
+
+	// SpanFetch covers each request for a page of results.
+	SpanFetch = "presto.fetch"
+
+	// SpanQueued covers the time from the query's submission until it leaves the
+	// server's queue.
+	SpanQueued = "presto.queued"
+
+	// SpanExecution covers the time from the query leaving the queue until its last
+	// page of results has been received or it fails.
+	SpanExecution = "presto.execution"
+
+	// SpanRows covers the consumption of the query's rows, from its submission until
+	// the rows are closed.
+	SpanRows = "presto.rows"
+)
+
+// SpanInfo describes the operation covered by a span.
+type SpanInfo struct {
+	QueryID string
+
+	// State is the state of the query, such as QueryStateRunning, when the span
+	// ended.
+	State string
+
+	// Rows is the number of rows received by a SpanFetch span or read from a
+	// SpanRows span.
+	Rows int64
+
+	// Bytes is the number of bytes received by a SpanFetch span, before any
+	// decompression, or received for the whole query by a SpanRows span.
+	Bytes int64
+
+	// Err is the error, if any, that ended the operation.
+	Err error
+}
+
+// Tracer receives the start and end of the operations involved in running a query,
+// so that they may be recorded as the spans of a distributed trace. Spans are nested
+// within the span, if any, of the context used to run the query. When pages are
+// prefetched, spans may be started and ended from a goroutine other than the one
+// reading the rows.
+type Tracer interface {
+	// Start is called when the operation named by span begins. The context it
+	// returns is passed to the matching call to End and is used for the requests
+	// made by the operation.
+	Start(ctx context.Context, span string, info SpanInfo) context.Context
+
+	// End is called when the operation finishes.
+	End(ctx context.Context, span string, info SpanInfo)
+
+	// TraceToken returns the trace token to send with a query started with ctx, or
+	// an empty string to use the configured trace token. The trace token appears in
+	// the Presto server's logs, so that they may be matched with the trace.
+	TraceToken(ctx context.Context) string
+}
+
+// startSpan reports the start of an operation to the connection's tracer, if it has
+// one.
+func (c *conn) startSpan(ctx context.Context, span string, info SpanInfo) context.Context {
+	if c.tracer == nil {
+		return ctx
+	}
+	return c.tracer.Start(ctx, span, info)
+}
+
+// endSpan reports the end of an operation to the connection's tracer, if it has one.
+func (c *conn) endSpan(ctx context.Context, span string, info SpanInfo) {
+	if c.tracer != nil {
+		c.tracer.End(ctx, span, info)
+	}
+}
+
+// rowsTrace holds the spans of a query that are open while its rows are read.
+type rowsTrace struct {
+	state    string
+	phase    string // SpanQueued or SpanExecution, or empty once both have ended
+	phaseCtx context.Context
+	rowsCtx  context.Context
+}
+
+// startTrace starts the spans covering the queueing and execution of the query and the
+// consumption of its rows.
+func (r *rows) startTrace(ctx context.Context, state string) {
+	if r.conn.tracer == nil {
+		return
+	}
+	r.trace.state = state
+	r.trace.rowsCtx = r.conn.startSpan(ctx, SpanRows, SpanInfo{QueryID: r.id})
+	r.trace.phase = SpanQueued
+	if state != QueryStateQueued {
+		r.trace.phase = SpanExecution
+	}
+	r.trace.phaseCtx = r.conn.startSpan(ctx, r.trace.phase, SpanInfo{QueryID: r.id})
+}
+
+// traceState records the state of the query reported in a page of results, moving
+// from the queueing span to the execution span when the query leaves the queue and
+// ending the execution span with the last page.
+func (r *rows) traceState(qresp *queryResponse) {
+	if r.conn.tracer == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.state = qresp.Stats.State
+	if r.trace.phase == SpanQueued && qresp.Stats.State != QueryStateQueued {
+		r.endPhase(nil)
+		r.trace.phase = SpanExecution
+		r.trace.phaseCtx = r.conn.startSpan(r.context(), SpanExecution, SpanInfo{QueryID: r.id})
+	}
+	if qresp.NextURI == "" && qresp.Error == nil {
+		r.endPhase(nil)
+	}
+}
+
+// traceFailure ends the open queueing or execution span with err.
+func (r *rows) traceFailure(err error) {
+	if r.conn.tracer == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.endPhase(err)
+}
+
+// endPhase ends the queueing or execution span, if it is open. r.mu must be held.
+func (r *rows) endPhase(err error) {
+	if r.trace.phase == "" {
+		return
+	}
+	r.conn.endSpan(r.trace.phaseCtx, r.trace.phase, SpanInfo{QueryID: r.id, State: r.trace.state, Err: err})
+	r.trace.phase = ""
+}
+
+// endTrace ends the spans that remain open when the rows are closed.
+func (r *rows) endTrace() {
+	if r.conn.tracer == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.endPhase(nil)
+	r.conn.endSpan(r.trace.rowsCtx, SpanRows, SpanInfo{
+		QueryID: r.id,
+		State:   r.trace.state,
+		Rows:    r.delivered,
+		Bytes:   r.stats.ReceivedBytes,
+	})
+}
@@ -0,0 +1,132 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type traceIDKey struct{}
+
+// recordingTracer records the spans it is given, using the trace id stored in the
+// context as the trace token.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+	bytes int64
+}
+
+func (t *recordingTracer) Start(ctx context.Context, span string, info SpanInfo) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, "start "+span)
+	return ctx
+}
+
+func (t *recordingTracer) End(ctx context.Context, span string, info SpanInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, fmt.Sprintf("end %s %s %s rows=%d err=%v", span, info.QueryID, info.State, info.Rows, info.Err))
+	if span == SpanRows {
+		t.bytes = info.Bytes
+	}
+}
+
+func (t *recordingTracer) TraceToken(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+func TestTracer(t *testing.T) {
+	var tokens []string
+	var mu sync.Mutex
+	s := &pagedServer{pages: 2}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/statement" {
+			mu.Lock()
+			tokens = append(tokens, r.Header.Get("X-Presto-Trace-Token"))
+			mu.Unlock()
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tracer := &recordingTracer{}
+	connector, err := NewConnector(Config{
+		DSN:    "presto://" + strings.TrimPrefix(ts.URL, "http://") + "?trace_token=configured",
+		Tracer: tracer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, _ := connector.Connect(context.Background())
+
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "trace-1")
+	r, err := cn.(driver.QueryerContext).QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := make([]driver.Value, 1)
+	for r.Next(dest) == nil {
+	}
+	r.Close()
+
+	expected := []string{
+		"start presto.submit",
+		"end presto.submit abcd QUEUED rows=0 err=<nil>",
+		"start presto.rows",
+		"start presto.queued",
+		"start presto.fetch",
+		"end presto.queued abcd RUNNING rows=0 err=<nil>",
+		"start presto.execution",
+		"end presto.fetch abcd RUNNING rows=1 err=<nil>",
+		"start presto.fetch",
+		"end presto.execution abcd FINISHED rows=0 err=<nil>",
+		"end presto.fetch abcd FINISHED rows=1 err=<nil>",
+		"end presto.rows abcd FINISHED rows=2 err=<nil>",
+	}
+	if !reflect.DeepEqual(tracer.spans, expected) {
+		t.Errorf("got spans\n%s\nwanted\n%s", strings.Join(tracer.spans, "\n"), strings.Join(expected, "\n"))
+	}
+	if tracer.bytes == 0 {
+		t.Errorf("got no bytes received reported for the rows")
+	}
+
+	// The configured token is used when the tracer has none
+	r, err = cn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if expected := []string{"trace-1", "configured"}; !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("got trace tokens %q, wanted %q", tokens, expected)
+	}
+}
+
+func TestTracerFailure(t *testing.T) {
+	tracer := &recordingTracer{}
+	s := &stuckServer{state: QueryStateQueued}
+	r, done := testQuery(t, context.Background(), s, Config{Tracer: tracer, Limits: QueryLimits{MaxQueueTime: 1}}, "SELECT 1")
+	defer done()
+
+	if err := r.Next(make([]driver.Value, 1)); err == nil {
+		t.Fatal("got no error, wanted the query to fail")
+	}
+	r.Close()
+
+	var ended []string
+	for _, span := range tracer.spans {
+		if strings.HasPrefix(span, "end presto.queued") || strings.HasPrefix(span, "end presto.execution") {
+			ended = append(ended, span)
+		}
+	}
+	if len(ended) != 1 || !strings.Contains(ended[0], "exceeded its maximum queue time") {
+		t.Errorf("got %q, wanted the queued span to end with the limit error", ended)
+	}
+}
@@ -1,5 +1,7 @@
 package prestgo
 
+import "database/sql/driver"
+
 const (
 	// This type captures boolean values true and false
 	Boolean = "boolean"
@@ -46,11 +48,12 @@ const (
 )
 
 type stmtResponse struct {
-	ID      string    `json:"id"`
-	InfoURI string    `json:"infoUri"`
-	NextURI string    `json:"nextUri"`
-	Stats   stmtStats `json:"stats"`
-	Error   stmtError `json:"error"`
+	ID       string      `json:"id"`
+	InfoURI  string      `json:"infoUri"`
+	NextURI  string      `json:"nextUri"`
+	Stats    stmtStats   `json:"stats"`
+	Error    *QueryError `json:"error"`
+	Warnings []Warning   `json:"warnings"`
 }
 
 type stmtStats struct {
@@ -67,28 +70,16 @@ type stmtStats struct {
 	ProcessedRows   int       `json:"processedRows"`
 	ProcessedBytes  int       `json:"processedBytes"`
 	RootStage       stmtStage `json:"rootStage"`
-}
-
-type stmtError struct {
-	Message       string               `json:"message"`
-	ErrorCode     int                  `json:"errorCode"`
-	ErrorLocation stmtErrorLocation    `json:"errorLocation"`
-	FailureInfo   stmtErrorFailureInfo `json:"failureInfo"`
-	// Other fields omitted
-}
 
-type stmtErrorLocation struct {
-	LineNumber   int `json:"lineNumber"`
-	ColumnNumber int `json:"columnNumber"`
-}
-
-type stmtErrorFailureInfo struct {
-	Type string `json:"type"`
-	// Other fields omitted
-}
+	// Reported by newer Presto and Trino servers only
+	Queued            bool  `json:"queued"`
+	ElapsedTimeMillis int   `json:"elapsedTimeMillis"`
+	QueuedTimeMillis  int   `json:"queuedTimeMillis"`
+	PeakMemoryBytes   int64 `json:"peakMemoryBytes"`
+	SpilledBytes      int64 `json:"spilledBytes"`
 
-func (e stmtError) Error() string {
-	return e.FailureInfo.Type + ": " + e.Message
+	// Reported by Trino only
+	ProgressPercentage *float64 `json:"progressPercentage"`
 }
 
 type stmtStage struct {
@@ -109,14 +100,18 @@ type stmtStage struct {
 }
 
 type queryResponse struct {
-	ID               string        `json:"id"`
-	InfoURI          string        `json:"infoUri"`
-	PartialCancelURI string        `json:"partialCancelUri"`
-	NextURI          string        `json:"nextUri"`
-	Columns          []queryColumn `json:"columns"`
-	Data             []queryData   `json:"data"`
-	Stats            stmtStats     `json:"stats"`
-	Error            stmtError     `json:"error"`
+	ID               string           `json:"id"`
+	InfoURI          string           `json:"infoUri"`
+	PartialCancelURI string           `json:"partialCancelUri"`
+	NextURI          string           `json:"nextUri"`
+	Columns          []queryColumn    `json:"columns"`
+	Data             [][]driver.Value `json:"-"` // decoded by rows.decodeResponse
+	Spooled          *spooledData     `json:"-"` // decoded by rows.decodeResponse
+	Stats            stmtStats        `json:"stats"`
+	Error            *QueryError      `json:"error"`
+	Warnings         []Warning        `json:"warnings"`
+	UpdateType       string           `json:"updateType"`
+	UpdateCount      *int64           `json:"updateCount"`
 }
 
 type queryColumn struct {
@@ -125,17 +120,47 @@ type queryColumn struct {
 	TypeSignature typeSignature `json:"typeSignature"`
 }
 
-type queryData []interface{}
-
 type typeSignature struct {
 	RawType          string        `json:"rawType"`
 	TypeArguments    []interface{} `json:"typeArguments"`
 	LiteralArguments []interface{} `json:"literalArguments"`
 }
 
+// infoResponse is a query's information as returned by /v1/query. The list of queries
+// holds a subset of the fields returned for a single query.
 type infoResponse struct {
-	QueryID string `json:"queryId"`
-	State   string `json:"state"`
+	QueryID     string         `json:"queryId"`
+	State       string         `json:"state"`
+	Session     infoSession    `json:"session"`
+	Self        string         `json:"self"`
+	Query       string         `json:"query"`
+	QueryStats  infoStats      `json:"queryStats"`
+	ErrorType   string         `json:"errorType"`
+	ErrorCode   *infoErrorCode `json:"errorCode"`
+	FailureInfo *FailureInfo   `json:"failureInfo"`
+	Warnings    []Warning      `json:"warnings"`
+}
+
+type infoSession struct {
+	User    string `json:"user"`
+	Source  string `json:"source"`
+	Catalog string `json:"catalog"`
+	Schema  string `json:"schema"`
+}
+
+type infoStats struct {
+	CreateTime    string `json:"createTime"`
+	EndTime       string `json:"endTime"`
+	ElapsedTime   string `json:"elapsedTime"`
+	QueuedTime    string `json:"queuedTime"`
+	ExecutionTime string `json:"executionTime"`
+	CPUTime       string `json:"totalCpuTime"`
+}
+
+type infoErrorCode struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 const (
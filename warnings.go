@@ -0,0 +1,54 @@
+package prestgo
+
+// Warning is a non-fatal problem reported by Presto while executing a query, such as
+// the use of a deprecated function or a hint about the query's performance.
+type Warning struct {
+	Code    WarningCode `json:"warningCode"`
+	Message string      `json:"message"`
+}
+
+// WarningCode identifies the kind of a Warning.
+type WarningCode struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+}
+
+// WarningHandler is called with each distinct warning reported for a query.
+type WarningHandler func(queryID string, w Warning)
+
+// WarningsProvider reports the warnings returned for a query. Like QueryInfo, it is
+// implemented by the driver.Rows returned by the driver's connections. Code using
+// database/sql can receive warnings through Config.OnWarning instead.
+type WarningsProvider interface {
+	// Warnings returns the distinct warnings reported so far for the query that
+	// produced the rows.
+	Warnings() []Warning
+}
+
+var _ WarningsProvider = &rows{}
+
+func (r *rows) Warnings() []Warning {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Warning(nil), r.warnings...)
+}
+
+// addWarnings records the warnings reported in a response. Presto repeats warnings
+// in each response so only those not seen before are kept.
+func (r *rows) addWarnings(ws []Warning) {
+next:
+	for _, w := range ws {
+		r.mu.Lock()
+		for _, seen := range r.warnings {
+			if w == seen {
+				r.mu.Unlock()
+				continue next
+			}
+		}
+		r.warnings = append(r.warnings, w)
+		r.mu.Unlock()
+		if r.conn.onWarning != nil {
+			r.conn.onWarning(r.id, w)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package prestgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var warningResponse = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/statement":
+		fmt.Fprintf(w, `{
+		  "id": "abcd",
+		  "nextUri": "http://%s/v1/query/abcd/1",
+		  "stats": {"state": "QUEUED"},
+		  "warnings": [{"warningCode": {"code": 1, "name": "PARSER_WARNING"}, "message": "deprecated syntax"}]
+		}`, r.Host)
+	case "/v1/query/abcd/1":
+		fmt.Fprint(w, `{
+		  "id": "abcd",
+		  "columns": [
+		    { "name": "col0", "type": "varchar", "typeSignature": { "rawType": "varchar", "typeArguments": [], "literalArguments": [] } }
+		  ],
+		  "data": [ [ "c0r0" ] ],
+		  "stats": {"state": "FINISHED"},
+		  "warnings": [
+		    {"warningCode": {"code": 1, "name": "PARSER_WARNING"}, "message": "deprecated syntax"},
+		    {"warningCode": {"code": 7, "name": "PERFORMANCE_WARNING"}, "message": "cross join"}
+		  ]
+		}`)
+	default:
+		http.NotFound(w, r)
+	}
+})
+
+func TestWarnings(t *testing.T) {
+	ts := httptest.NewServer(warningResponse)
+	defer ts.Close()
+
+	var handled []string
+	connector, err := NewConnector(Config{
+		DSN: "presto://" + strings.TrimPrefix(ts.URL, "http://"),
+		OnWarning: func(queryID string, w Warning) {
+			handled = append(handled, queryID+": "+w.Code.Name)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	cn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cn.Close()
+
+	var warnings []Warning
+	err = cn.Raw(func(dc interface{}) error {
+		r, err := dc.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for r.Next(make([]driver.Value, 1)) == nil {
+		}
+		warnings = r.(WarningsProvider).Warnings()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Warning{
+		{Code: WarningCode{Code: 1, Name: "PARSER_WARNING"}, Message: "deprecated syntax"},
+		{Code: WarningCode{Code: 7, Name: "PERFORMANCE_WARNING"}, Message: "cross join"},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("got warnings %v, wanted %v", warnings, expected)
+	}
+	if expected := []string{"abcd: PARSER_WARNING", "abcd: PERFORMANCE_WARNING"}; !reflect.DeepEqual(handled, expected) {
+		t.Errorf("got handled %v, wanted %v", handled, expected)
+	}
+}